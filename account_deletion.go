@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+func (cfg *apiConfig) deleteAccount(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	type response struct {
+		DeletionScheduledFor time.Time `json:"deletion_scheduled_for"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	match, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if err != nil || !match {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// Scheduling the deletion and signing out go together, so an account
+	// can't be left scheduled with its sessions still live.
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		user, err = q.RequestUserDeletion(req.Context(), user.ID)
+		if err != nil {
+			return err
+		}
+		return q.RevokeRefreshTokensForUser(req.Context(), user.ID)
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(response{
+		DeletionScheduledFor: user.DeletionRequestedAt.Time.Add(cfg.deletionGracePeriod),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(dat)
+}
+
+// purgeDeletedAccounts hard-deletes accounts whose grace period has run out.
+// Their chirps are deleted first so their removal can be announced; refresh
+// tokens go with the accounts through ON DELETE CASCADE.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context) {
+	cutoff := time.Now().Add(-cfg.deletionGracePeriod)
+	var chirps []database.Chirp
+	var purged int64
+	err := cfg.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		chirps, err = q.DeleteChirpsOfPurgedUsers(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		purged, err = q.PurgeDeletedUsers(ctx, cutoff)
+		return err
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, chirp := range chirps {
+		cfg.announceChirpRemoved(ctx, chirp)
+	}
+	if purged > 0 {
+		fmt.Printf("purged %d deleted accounts\n", purged)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/profanity"
+)
+
+const (
+	rescanPageSize = 500
+	// rescanJobRetention is how long a finished rescan report stays available.
+	rescanJobRetention = 24 * time.Hour
+)
+
+type BannedWord struct {
+	Word      string    `json:"word"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `json:"action"`
+}
+
+// loadProfanityFilter builds the filter from the banned_words table plus,
+// when PROFANITY_WORDS_FILE is set, the words listed in that file.
+func (cfg *apiConfig) loadProfanityFilter(ctx context.Context) (*profanity.Filter, error) {
+	rows, err := cfg.db.GetBannedWords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := []profanity.Entry{}
+	for _, row := range rows {
+		entries = append(entries, profanity.Entry{
+			Word:   row.Word,
+			Action: profanity.Action(row.Action),
+		})
+	}
+	if cfg.profanityWordsFile != "" {
+		fileEntries, err := profanity.LoadFile(cfg.profanityWordsFile)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return profanity.New(entries), nil
+}
+
+// reloadProfanityFilter swaps in a filter built from the current word list.
+// Requests already checking a chirp finish with the filter they started with.
+func (cfg *apiConfig) reloadProfanityFilter(ctx context.Context) error {
+	// A slow reload that read an older word list must not store its filter
+	// over a newer one.
+	cfg.profanityReloadMu.Lock()
+	defer cfg.profanityReloadMu.Unlock()
+	filter, err := cfg.loadProfanityFilter(ctx)
+	if err != nil {
+		return err
+	}
+	cfg.profanityFilter.Store(filter)
+	return nil
+}
+
+func (cfg *apiConfig) getBannedWords(w http.ResponseWriter, req *http.Request) {
+	rows, err := cfg.db.GetBannedWords(req.Context())
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	words := []BannedWord{}
+	for _, row := range rows {
+		words = append(words, bannedWordFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(words)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) createBannedWord(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Word   string `json:"word"`
+		Action string `json:"action"`
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if params.Action == "" {
+		params.Action = string(profanity.ActionMask)
+	}
+	action, err := profanity.ParseAction(params.Action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Words are stored in the form the filter compares them in, so "K3rfuffle"
+	// and "kerfuffle" are the same entry.
+	word := profanity.Normalize(params.Word)
+	if word == "" {
+		respondWithError(w, http.StatusBadRequest, "Word must contain letters or digits")
+		return
+	}
+
+	row, err := cfg.db.UpsertBannedWord(req.Context(), database.UpsertBannedWordParams{
+		Word:   word,
+		Action: string(action),
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if err := cfg.reloadProfanityFilter(req.Context()); err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.publishEvent(req.Context(), topicBannedWordsChanged, nil)
+
+	dat, err := json.Marshal(bannedWordFromDatabase(row))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) deleteBannedWord(w http.ResponseWriter, req *http.Request) {
+	deleted, err := cfg.db.DeleteBannedWord(req.Context(), profanity.Normalize(req.PathValue("word")))
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := cfg.reloadProfanityFilter(req.Context()); err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.publishEvent(req.Context(), topicBannedWordsChanged, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type RescanMatch struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	UserID  uuid.UUID `json:"user_id"`
+	Words   []string  `json:"words"`
+	Action  string    `json:"action"`
+}
+
+type RescanJob struct {
+	ID         uuid.UUID     `json:"id"`
+	Status     string        `json:"status"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Scanned    int           `json:"scanned"`
+	Matches    []RescanMatch `json:"matches"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// rescanJobRegistry keeps rescan reports in memory; they are diagnostics for
+// admins and don't need to survive a restart.
+type rescanJobRegistry struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]*RescanJob
+}
+
+func newRescanJobRegistry() *rescanJobRegistry {
+	return &rescanJobRegistry{jobs: map[uuid.UUID]*RescanJob{}}
+}
+
+// start registers a new running job, or returns false if one is already
+// running.
+func (r *rescanJobRegistry) start() (RescanJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, job := range r.jobs {
+		if job.Status == "running" {
+			return RescanJob{}, false
+		}
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > rescanJobRetention {
+			delete(r.jobs, id)
+		}
+	}
+	job := &RescanJob{
+		ID:        uuid.New(),
+		Status:    "running",
+		StartedAt: time.Now(),
+		Matches:   []RescanMatch{},
+	}
+	r.jobs[job.ID] = job
+	return *job, true
+}
+
+func (r *rescanJobRegistry) get(id uuid.UUID) (RescanJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return RescanJob{}, false
+	}
+	res := *job
+	res.Matches = append([]RescanMatch{}, job.Matches...)
+	return res, true
+}
+
+func (r *rescanJobRegistry) update(id uuid.UUID, fn func(job *RescanJob)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if job, ok := r.jobs[id]; ok {
+		fn(job)
+	}
+}
+
+func (cfg *apiConfig) startRescan(w http.ResponseWriter, req *http.Request) {
+	job, ok := cfg.rescanJobs.start()
+	if !ok {
+		respondWithError(w, http.StatusConflict, "A rescan is already running")
+		return
+	}
+	go cfg.rescanChirps(context.Background(), job.ID, cfg.profanityFilter.Load())
+
+	dat, err := json.Marshal(job)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getRescan(w http.ResponseWriter, req *http.Request) {
+	jobID, err := uuid.Parse(req.PathValue("jobID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	job, ok := cfg.rescanJobs.get(jobID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	dat, err := json.Marshal(job)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// rescanChirps checks every stored chirp against filter and records the ones
+// that match. It only reports; chirps are left as they are.
+func (cfg *apiConfig) rescanChirps(ctx context.Context, jobID uuid.UUID, filter *profanity.Filter) {
+	after := database.GetChirpsPageParams{PageSize: rescanPageSize}
+	for {
+		chirps, err := cfg.db.GetChirpsPage(ctx, after)
+		if err != nil {
+			fmt.Println(err)
+			cfg.rescanJobs.update(jobID, func(job *RescanJob) {
+				now := time.Now()
+				job.Status = "failed"
+				job.FinishedAt = &now
+				job.Error = err.Error()
+			})
+			return
+		}
+
+		matches := []RescanMatch{}
+		for _, chirp := range chirps {
+			res := filter.Check(chirp.Body)
+			if len(res.Matches) == 0 {
+				continue
+			}
+			match := RescanMatch{
+				ChirpID: chirp.ID,
+				UserID:  chirp.UserID,
+				Action:  string(profanity.ActionMask),
+			}
+			for _, m := range res.Matches {
+				match.Words = append(match.Words, m.Entry)
+			}
+			if res.Rejected {
+				match.Action = string(profanity.ActionReject)
+			} else if res.Flagged {
+				match.Action = string(profanity.ActionFlag)
+			}
+			matches = append(matches, match)
+		}
+		cfg.rescanJobs.update(jobID, func(job *RescanJob) {
+			job.Scanned += len(chirps)
+			job.Matches = append(job.Matches, matches...)
+		})
+
+		if len(chirps) < rescanPageSize {
+			break
+		}
+		last := chirps[len(chirps)-1]
+		after.AfterCreatedAt = last.CreatedAt
+		after.AfterID = last.ID
+	}
+
+	cfg.rescanJobs.update(jobID, func(job *RescanJob) {
+		now := time.Now()
+		job.Status = "done"
+		job.FinishedAt = &now
+	})
+}
+
+func bannedWordFromDatabase(row database.BannedWord) BannedWord {
+	return BannedWord{
+		Word:      row.Word,
+		CreatedAt: row.CreatedAt,
+		Action:    row.Action,
+	}
+}
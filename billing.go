@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/billing"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+const (
+	maxWebhookBodySize = 1 << 20
+	// webhookTolerance is how far a webhook's signed timestamp may be from
+	// our clock. Anything older is treated as a replay.
+	webhookTolerance = 5 * time.Minute
+)
+
+// billingSubscriptionChanges maps each billing event to the subscription
+// change it makes.
+var billingSubscriptionChanges = map[billing.EventType]subscriptionChange{
+	billing.EventStarted:       {Event: subscriptionEventUpgraded, Status: subscriptionActive},
+	billing.EventRenewed:       {Event: subscriptionEventRenewed, Status: subscriptionActive},
+	billing.EventPaymentFailed: {Event: subscriptionEventPaymentFailed, Status: subscriptionPastDue},
+	billing.EventCancelled:     {Event: subscriptionEventCancelled, Status: subscriptionCancelled},
+	billing.EventEnded:         {Event: subscriptionEventDowngraded, Status: subscriptionExpired},
+}
+
+// loadBillingProviders sets up every payment provider Chirpy accepts
+// webhooks from, keyed by name. Each also needs a route in main.
+func loadBillingProviders() map[string]billing.Provider {
+	providers := map[string]billing.Provider{}
+	for _, provider := range []billing.Provider{
+		billing.Polka{Secrets: loadPolkaSecrets(), Tolerance: webhookTolerance},
+	} {
+		providers[provider.Name()] = provider
+	}
+	return providers
+}
+
+// loadPolkaSecrets reads the webhook signing secrets from POLKA_SECRETS, a
+// comma separated list so a new secret can be added before the old one is
+// retired. POLKA_SECRET is still read for a single secret.
+func loadPolkaSecrets() []string {
+	secrets := []string{}
+	for _, secret := range strings.Split(os.Getenv("POLKA_SECRETS")+","+os.Getenv("POLKA_SECRET"), ",") {
+		secret = strings.TrimSpace(secret)
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
+
+// billingWebhook handles webhooks from one payment provider. Every verified
+// event is stored before it is applied, and retries of an event that was
+// already handled are acknowledged without applying it again.
+func (cfg *apiConfig) billingWebhook(name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		provider := cfg.billingProviders[name]
+
+		// The signature covers the exact bytes sent, so read the raw body
+		// and verify it before decoding anything.
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxWebhookBodySize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = provider.Verify(req.Header, body, time.Now())
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		billingEvent, err := provider.Parse(req.Header, body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		event, err := cfg.recordWebhookEvent(req.Context(), provider.Name(), billingEvent.ID, billingEvent.ProviderType, body)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if event.Status == webhookEventProcessed || event.Status == webhookEventIgnored {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		event, err = cfg.processWebhookEvent(req.Context(), event)
+		if errors.Is(err, errWebhookEventClaimed) {
+			// Another delivery of the same event is being applied. The
+			// provider retries, and by then the event has been handled.
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if event.Status == webhookEventFailed {
+			// The provider is told 404 for an unknown customer so it stops
+			// retrying.
+			if event.Error.String == billing.ErrUnknownCustomer.Error() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// applyBillingWebhook applies a stored webhook from a payment provider. It
+// reports whether the event was one we act on.
+func (cfg *apiConfig) applyBillingWebhook(ctx context.Context, event database.WebhookEvent) (bool, error) {
+	provider, ok := cfg.billingProviders[event.Source]
+	if !ok {
+		return false, fmt.Errorf("no billing provider named %q", event.Source)
+	}
+	// Headers aren't stored, and are only needed for the event ID, which
+	// is already known.
+	billingEvent, err := provider.Parse(http.Header{}, event.Payload)
+	if err != nil {
+		return false, err
+	}
+	billingEvent.ID = event.EventID
+	return cfg.applyBillingEvent(ctx, provider, billingEvent)
+}
+
+// applyBillingEvent is the one path by which payment providers change
+// subscriptions.
+func (cfg *apiConfig) applyBillingEvent(ctx context.Context, provider billing.Provider, event billing.Event) (bool, error) {
+	change, ok := billingSubscriptionChanges[event.Type]
+	if !ok {
+		return false, nil
+	}
+	userID, err := provider.UserID(ctx, event.CustomerID)
+	if err != nil {
+		return false, err
+	}
+
+	change.Plan = event.Plan
+	change.PeriodStart = event.PeriodStart
+	change.PeriodEnd = event.PeriodEnd
+	change.Source = provider.Name()
+	change.SourceEventID = event.ID
+	_, err = cfg.applySubscriptionChange(ctx, userID, change)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, billing.ErrUnknownCustomer
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+// RelatedUser is an entry in a user's block or mute list.
+type RelatedUser struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) blockUser(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, req)
+	if !ok {
+		return
+	}
+	err := cfg.db.CreateUserBlock(req.Context(), database.CreateUserBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unblockUser(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, req)
+	if !ok {
+		return
+	}
+	deleted, err := cfg.db.DeleteUserBlock(req.Context(), database.DeleteUserBlockParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) muteUser(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, req)
+	if !ok {
+		return
+	}
+	err := cfg.db.CreateUserMute(req.Context(), database.CreateUserMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unmuteUser(w http.ResponseWriter, req *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, req)
+	if !ok {
+		return
+	}
+	deleted, err := cfg.db.DeleteUserMute(req.Context(), database.DeleteUserMuteParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getBlockedUsers(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := cfg.db.GetUserBlocks(req.Context(), userID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dat, err := json.Marshal(blockedUsersFromDatabase(blocks))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getMutedUsers(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	mutes, err := cfg.db.GetUserMutes(req.Context(), userID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dat, err := json.Marshal(mutedUsersFromDatabase(mutes))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// relationshipTarget authenticates the caller and reads the user they want to
+// block or mute from the path, which must be an existing user other than
+// themselves.
+func (cfg *apiConfig) relationshipTarget(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't block or mute yourself")
+		return uuid.Nil, uuid.Nil, false
+	}
+	if _, err := cfg.db.GetUserByID(req.Context(), targetID); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, targetID, true
+}
+
+func blockedUsersFromDatabase(blocks []database.UserBlock) []RelatedUser {
+	res := []RelatedUser{}
+	for _, block := range blocks {
+		res = append(res, RelatedUser{UserID: block.BlockedID, CreatedAt: block.CreatedAt})
+	}
+	return res
+}
+
+func mutedUsersFromDatabase(mutes []database.UserMute) []RelatedUser {
+	res := []RelatedUser{}
+	for _, mute := range mutes {
+		res = append(res, RelatedUser{UserID: mute.MutedID, CreatedAt: mute.CreatedAt})
+	}
+	return res
+}
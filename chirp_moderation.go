@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+var moderationStatuses = []string{"visible", "flagged", "hidden", "held"}
+
+// getModerationChirps lists chirps in a moderation state, oldest first. By
+// default it returns the held chirps waiting for review.
+func (cfg *apiConfig) getModerationChirps(w http.ResponseWriter, req *http.Request) {
+	status := req.URL.Query().Get("status")
+	if status == "" {
+		status = "held"
+	}
+	if !slices.Contains(moderationStatuses, status) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	dbChirps, err := cfg.db.GetChirpsByModerationStatus(req.Context(), status)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	chirps := []ModeratedChirp{}
+	for _, chirp := range dbChirps {
+		chirps = append(chirps, moderatedChirpFromDatabase(chirp))
+	}
+
+	dat, err := json.Marshal(chirps)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) updateChirpModerationStatus(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	moderatorID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil || !slices.Contains(moderationStatuses, params.Status) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	chirp, err := cfg.db.UpdateChirpModerationStatus(req.Context(), database.UpdateChirpModerationStatusParams{
+		ModerationStatus: params.Status,
+		ID:               chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_, err = cfg.db.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorID,
+		Action:       "set_chirp_status_" + params.Status,
+		ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Note:         params.Note,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Approving a held chirp publishes it, and hiding or holding a public
+	// one takes it back.
+	cfg.announceChirp(req.Context(), chirp)
+	cfg.withdrawChirp(req.Context(), chirp.ID)
+
+	dat, err := json.Marshal(moderatedChirpFromDatabase(chirp))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
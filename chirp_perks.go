@@ -0,0 +1,244 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+	"github.com/ifeanyibatman/chirpy/internal/moderation"
+	"github.com/ifeanyibatman/chirpy/internal/spam"
+)
+
+// maxScheduleAhead is how far in the future a chirp can be scheduled.
+const maxScheduleAhead = 90 * 24 * time.Hour
+
+// moderationSeverity orders statuses so an edit can make a chirp stricter
+// but never undo a moderator's decision.
+var moderationSeverity = map[string]int{
+	"visible": 0,
+	"flagged": 1,
+	"held":    2,
+	"hidden":  3,
+}
+
+func (cfg *apiConfig) editChirp(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	chirp, userID, capabilities, ok := cfg.ownChirp(w, req)
+	if !ok {
+		return
+	}
+	if !capabilities.EditChirps {
+		respondWithError(w, http.StatusForbidden, "Your plan doesn't include editing chirps")
+		return
+	}
+	if chirp.ModerationStatus == "hidden" {
+		respondWithError(w, http.StatusForbidden, "This chirp has been hidden by a moderator")
+		return
+	}
+
+	params := parameters{}
+	err := json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(params.Body) > capabilities.MaxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
+	}
+
+	// Edits go through the same moderation as new chirps.
+	moderated, err := cfg.moderator.Moderate(req.Context(), moderation.Content{
+		UserID:         userID,
+		Body:           params.Body,
+		ContentWarning: chirp.ContentWarning.String,
+		Sensitive:      chirp.Sensitive,
+	})
+	if errors.Is(err, moderation.ErrUnavailable) {
+		fmt.Println(err)
+		respondWithError(w, http.StatusServiceUnavailable, "Chirps can't be checked right now, please try again later")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	moderationStatus := chirp.ModerationStatus
+	switch moderated.Decision {
+	case moderation.DecisionReject:
+		respondWithError(w, http.StatusBadRequest, moderated.Reason)
+		return
+	case moderation.DecisionFlag:
+		if moderationSeverity["flagged"] > moderationSeverity[moderationStatus] {
+			moderationStatus = "flagged"
+		}
+	case moderation.DecisionHold:
+		if moderationSeverity["held"] > moderationSeverity[moderationStatus] {
+			moderationStatus = "held"
+		}
+	}
+	// A moderator that masked the body may have made it longer.
+	if len(moderated.Body) > capabilities.MaxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long once moderated")
+		return
+	}
+
+	// And through the same spam checks, so an edit can't turn a chirp into
+	// one that would have been refused.
+	verdict, err := cfg.checkSpam(req.Context(), userID, moderated.Body, chirp.ID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch verdict.Action {
+	case spam.ActionReject:
+		if verdict.Rule == spam.RuleVelocity {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, verdict.Reason)
+			return
+		}
+		respondWithError(w, http.StatusUnprocessableEntity, verdict.Reason)
+		return
+	case spam.ActionHold:
+		if moderationSeverity["held"] > moderationSeverity[moderationStatus] {
+			moderationStatus = "held"
+		}
+	}
+
+	chirp, err = cfg.db.UpdateChirpBody(req.Context(), database.UpdateChirpBodyParams{
+		Body:             moderated.Body,
+		ModerationStatus: moderationStatus,
+		ID:               chirp.ID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// An edit that is held for review takes the chirp down until then.
+	cfg.withdrawChirp(req.Context(), chirp.ID)
+	respondWithChirp(w, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) pinChirp(w http.ResponseWriter, req *http.Request) {
+	chirp, userID, capabilities, ok := cfg.ownChirp(w, req)
+	if !ok {
+		return
+	}
+	if chirp.PinnedAt.Valid {
+		respondWithChirp(w, http.StatusOK, chirp)
+		return
+	}
+	if chirp.ModerationStatus != "visible" && chirp.ModerationStatus != "flagged" {
+		respondWithError(w, http.StatusConflict, "Only published chirps can be pinned")
+		return
+	}
+
+	// The limit is checked by the update itself. Locking the author first
+	// makes concurrent pins by the same user take turns, so each one counts
+	// the others' pins.
+	err := cfg.inTx(req.Context(), func(q *database.Queries) error {
+		err := q.LockUser(req.Context(), userID)
+		if err != nil {
+			return err
+		}
+		chirp, err = q.PinChirp(req.Context(), database.PinChirpParams{
+			ID:        chirp.ID,
+			MaxPinned: int32(capabilities.MaxPinnedChirps),
+		})
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusForbidden, fmt.Sprintf("Your plan allows %d pinned chirps", capabilities.MaxPinnedChirps))
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respondWithChirp(w, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) unpinChirp(w http.ResponseWriter, req *http.Request) {
+	chirp, _, _, ok := cfg.ownChirp(w, req)
+	if !ok {
+		return
+	}
+	_, err := cfg.db.UnpinChirp(req.Context(), chirp.ID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownChirp loads the chirp named in the path for its author, along with
+// the author's capabilities. It writes the error response itself when the
+// caller isn't signed in as the author.
+func (cfg *apiConfig) ownChirp(w http.ResponseWriter, req *http.Request) (database.Chirp, uuid.UUID, entitlements.Capabilities, bool) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+	chirp, err := cfg.db.GetChirpByIDAnyStatus(req.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+	if chirp.UserID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+
+	capabilities, err := cfg.capabilities(req.Context(), userID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return database.Chirp{}, uuid.Nil, entitlements.Capabilities{}, false
+	}
+	return chirp, userID, capabilities, true
+}
+
+func respondWithChirp(w http.ResponseWriter, code int, chirp database.Chirp) {
+	dat, err := json.Marshal(chirpFromDatabase(chirp))
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(code)
+	w.Write(dat)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/stream"
+)
+
+// Channels a WebSocket client can subscribe to.
+const (
+	socketChannelHome     = "home"
+	socketChannelMentions = "mentions"
+	socketChannelThread   = "thread"
+)
+
+const (
+	socketPingInterval     = 30 * time.Second
+	socketWriteTimeout     = 10 * time.Second
+	socketMaxMessageSize   = 4096
+	socketMaxSubscriptions = 20
+	socketMaxIDLength      = 64
+)
+
+// socketRequest is a message from the client.
+type socketRequest struct {
+	Type    string    `json:"type"`
+	ID      string    `json:"id"`
+	Channel string    `json:"channel"`
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+// socketMessage is a message to the client. ID is the subscription the
+// message is about.
+type socketMessage struct {
+	Type    string `json:"type"`
+	ID      string `json:"id,omitempty"`
+	Event   string `json:"event,omitempty"`
+	EventID uint64 `json:"event_id,omitempty"`
+	Data    any    `json:"data,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// chirpSocket serves a WebSocket over which a signed-in user subscribes to
+// several chirp channels at once. Browsers can't set headers on a WebSocket,
+// so the access token may also be passed as the access_token query
+// parameter.
+func (cfg *apiConfig) chirpSocket(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		token = req.URL.Query().Get("access_token")
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	hidden, err := cfg.hiddenAuthors(req.Context(), userID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	conn, err := websocket.Accept(w, req, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	// The connection has been hijacked, so shutdown waits for it separately.
+	cfg.liveConnections.Add(1)
+	defer cfg.liveConnections.Done()
+	defer conn.CloseNow()
+	conn.SetReadLimit(socketMaxMessageSize)
+
+	ctx, cancel := context.WithCancel(cfg.shutdown)
+	defer cancel()
+
+	// The subscription's buffer is this connection's send buffer. A client
+	// that lets it fill up is disconnected rather than slowing down anyone
+	// posting chirps.
+	sub, _, _ := cfg.chirpStream.Subscribe(stream.Filter{}, 0)
+	defer sub.Close()
+	refresh := time.NewTicker(hiddenAuthorsRefresh)
+	defer refresh.Stop()
+
+	requests := make(chan socketRequest)
+	go readSocket(ctx, cancel, conn, requests)
+	go pingSocket(ctx, cancel, conn)
+
+	viewerID := uuid.NullUUID{UUID: userID, Valid: true}
+	preference := user.SensitiveContent
+	channels := map[string]stream.Filter{}
+	for {
+		select {
+		case <-ctx.Done():
+			if cfg.shutdown.Err() != nil {
+				conn.Close(websocket.StatusGoingAway, "server shutting down")
+			}
+			return
+		case request := <-requests:
+			reply := cfg.handleSocketRequest(ctx, request, viewerID, strings.ToLower(user.Email), channels)
+			if err := writeSocket(ctx, conn, reply); err != nil {
+				return
+			}
+		case <-refresh.C:
+			hidden = cfg.refreshHiddenAuthors(ctx, viewerID, hidden)
+		case event, ok := <-sub.Events:
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "too far behind")
+				return
+			}
+			if hidden[event.AuthorID] {
+				continue
+			}
+			for id, filter := range channels {
+				if !filter.Match(event) {
+					continue
+				}
+				msg, ok := socketEvent(id, event, viewerID, preference)
+				if !ok {
+					continue
+				}
+				if err := writeSocket(ctx, conn, msg); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) handleSocketRequest(ctx context.Context, request socketRequest, viewerID uuid.NullUUID, email string, channels map[string]stream.Filter) socketMessage {
+	fail := func(msg string) socketMessage {
+		return socketMessage{Type: "error", ID: request.ID, Error: msg}
+	}
+	if request.ID == "" || len(request.ID) > socketMaxIDLength {
+		return fail(fmt.Sprintf("id must be between 1 and %d characters", socketMaxIDLength))
+	}
+
+	switch request.Type {
+	case "subscribe":
+		if _, ok := channels[request.ID]; ok {
+			return fail("id is already in use")
+		}
+		if len(channels) >= socketMaxSubscriptions {
+			return fail(fmt.Sprintf("No more than %d subscriptions per connection", socketMaxSubscriptions))
+		}
+		var filter stream.Filter
+		switch request.Channel {
+		case socketChannelHome:
+		case socketChannelMentions:
+			filter.Mention = email
+		case socketChannelThread:
+			_, err := cfg.db.GetChirpByID(ctx, database.GetChirpByIDParams{
+				ID:       request.ChirpID,
+				ViewerID: viewerID,
+			})
+			if err != nil {
+				return fail("Chirp not found")
+			}
+			filter.ThreadID = uuid.NullUUID{UUID: request.ChirpID, Valid: true}
+		default:
+			return fail("channel must be home, mentions or thread")
+		}
+		channels[request.ID] = filter
+		return socketMessage{Type: "subscribed", ID: request.ID}
+	case "unsubscribe":
+		if _, ok := channels[request.ID]; !ok {
+			return fail("Unknown subscription")
+		}
+		delete(channels, request.ID)
+		return socketMessage{Type: "unsubscribed", ID: request.ID}
+	}
+	return fail("type must be subscribe or unsubscribe")
+}
+
+func socketEvent(id string, event stream.Event, viewerID uuid.NullUUID, preference string) (socketMessage, bool) {
+	chirp, ok := event.Payload.(database.Chirp)
+	if !ok {
+		return socketMessage{}, false
+	}
+	msg := socketMessage{Type: "event", ID: id, Event: event.Type, EventID: event.ID}
+	switch event.Type {
+	case streamChirpCreated:
+		presented, ok := presentChirp(chirp, viewerID, preference, false)
+		if !ok {
+			return socketMessage{}, false
+		}
+		msg.Data = presented
+	case streamChirpDeleted:
+		msg.Data = struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{ID: chirp.ID, UserID: chirp.UserID}
+	default:
+		return socketMessage{}, false
+	}
+	return msg, true
+}
+
+// readSocket passes the client's messages to requests until the connection
+// fails, then cancels the connection's context.
+func readSocket(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, requests chan<- socketRequest) {
+	defer cancel()
+	for {
+		// Cancelling a read drops the connection, so reads aren't tied to
+		// ctx. They end when the connection is closed, which lets a close
+		// handshake finish on shutdown.
+		typ, dat, err := conn.Read(context.Background())
+		if err != nil {
+			return
+		}
+		if typ != websocket.MessageText {
+			conn.Close(websocket.StatusUnsupportedData, "messages must be JSON text")
+			return
+		}
+		var request socketRequest
+		if err := json.Unmarshal(dat, &request); err != nil {
+			conn.Close(websocket.StatusInvalidFramePayloadData, "messages must be JSON")
+			return
+		}
+		select {
+		case requests <- request:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// pingSocket checks the client is still there, cancelling the connection's
+// context when it stops answering.
+func pingSocket(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) {
+	ticker := time.NewTicker(socketPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, pingCancel := context.WithTimeout(ctx, socketWriteTimeout)
+			err := conn.Ping(pingCtx)
+			pingCancel()
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func writeSocket(ctx context.Context, conn *websocket.Conn, msg socketMessage) error {
+	dat, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Println(err)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+	defer cancel()
+	return conn.Write(ctx, websocket.MessageText, dat)
+}
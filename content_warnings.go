@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+// How sensitive chirps (marked sensitive or carrying a content warning) are
+// shown to someone who didn't write them.
+const (
+	sensitiveExpand   = "expand"
+	sensitiveCollapse = "collapse"
+	sensitiveOmit     = "omit"
+)
+
+const maxContentWarningLength = 100
+
+func chirpFromDatabase(chirp database.Chirp) Chirp {
+	res := Chirp{
+		ID:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserID:    chirp.UserID,
+		Sensitive: chirp.Sensitive,
+	}
+	if chirp.ContentWarning.Valid {
+		res.ContentWarning = &chirp.ContentWarning.String
+	}
+	if chirp.EditedAt.Valid {
+		res.EditedAt = &chirp.EditedAt.Time
+	}
+	if chirp.PinnedAt.Valid {
+		res.PinnedAt = &chirp.PinnedAt.Time
+	}
+	res.Scheduled = chirp.CreatedAt.After(time.Now())
+	return res
+}
+
+// sensitiveContentPreference returns the viewer's preference. Anonymous
+// viewers get sensitive chirps collapsed.
+func (cfg *apiConfig) sensitiveContentPreference(ctx context.Context, viewerID uuid.NullUUID) string {
+	if !viewerID.Valid {
+		return sensitiveCollapse
+	}
+	user, err := cfg.db.GetUserByID(ctx, viewerID.UUID)
+	if err != nil {
+		return sensitiveCollapse
+	}
+	return user.SensitiveContent
+}
+
+// presentChirp applies the viewer's sensitive content preference to a chirp.
+// Authors always see their own chirps in full, and expand overrides a collapse
+// preference for a single request. It returns false when the chirp should be
+// left out entirely.
+func presentChirp(chirp database.Chirp, viewerID uuid.NullUUID, preference string, expand bool) (Chirp, bool) {
+	res := chirpFromDatabase(chirp)
+	if !chirp.Sensitive && !chirp.ContentWarning.Valid {
+		return res, true
+	}
+	if viewerID.Valid && viewerID.UUID == chirp.UserID {
+		return res, true
+	}
+	switch preference {
+	case sensitiveExpand:
+		return res, true
+	case sensitiveOmit:
+		return res, false
+	}
+	if !expand {
+		res.Body = ""
+		res.Collapsed = true
+	}
+	return res, true
+}
+
+func contentWarningParam(warning string) sql.NullString {
+	return sql.NullString{String: warning, Valid: warning != ""}
+}
+
+func (cfg *apiConfig) getPreferences(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	respondWithPreferences(w, user)
+}
+
+func (cfg *apiConfig) updatePreferences(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		SensitiveContent string `json:"sensitive_content"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	switch params.SensitiveContent {
+	case sensitiveExpand, sensitiveCollapse, sensitiveOmit:
+	default:
+		respondWithError(w, http.StatusBadRequest, "sensitive_content must be expand, collapse or omit")
+		return
+	}
+
+	user, err := cfg.db.UpdateUserSensitiveContent(req.Context(), database.UpdateUserSensitiveContentParams{
+		SensitiveContent: params.SensitiveContent,
+		ID:               userID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respondWithPreferences(w, user)
+}
+
+func respondWithPreferences(w http.ResponseWriter, user database.User) {
+	type preferences struct {
+		SensitiveContent string `json:"sensitive_content"`
+	}
+	dat, err := json.Marshal(preferences{SensitiveContent: user.SensitiveContent})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) updateChirpLabels(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		ContentWarning string `json:"content_warning"`
+		Sensitive      bool   `json:"sensitive"`
+		Note           string `json:"note"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	moderatorID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(params.ContentWarning) > maxContentWarningLength {
+		respondWithError(w, http.StatusBadRequest, "Content warning is too long")
+		return
+	}
+
+	chirp, err := cfg.db.UpdateChirpLabels(req.Context(), database.UpdateChirpLabelsParams{
+		ContentWarning: contentWarningParam(params.ContentWarning),
+		Sensitive:      params.Sensitive,
+		ID:             chirpID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	_, err = cfg.db.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorID,
+		Action:       "set_chirp_labels",
+		ChirpID:      uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		TargetUserID: uuid.NullUUID{UUID: chirp.UserID, Valid: true},
+		Note:         params.Note,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(moderatedChirpFromDatabase(chirp))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/dataexport"
+)
+
+// dataExportBuildDeadline is how long an export can stay pending. One that
+// takes longer, because the instance building it stopped, is marked failed.
+const dataExportBuildDeadline = time.Hour
+
+type DataExport struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Status    string     `json:"status"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (cfg *apiConfig) requestDataExport(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	export, err := cfg.db.CreateDataExport(req.Context(), database.CreateDataExportParams{
+		UserID:    userID,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(dataExportBuildDeadline), Valid: true},
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// The archive is built in the background; the request context would be
+	// cancelled as soon as this response is written.
+	go cfg.buildDataExport(context.Background(), export.ID, userID)
+
+	dat, err := json.Marshal(dataExportFromDatabase(export))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getDataExport(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	exportID, err := uuid.Parse(req.PathValue("exportID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	export, err := cfg.db.GetDataExport(req.Context(), database.GetDataExportParams{
+		ID:     exportID,
+		UserID: userID,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch export.Status {
+	case "pending":
+		dat, err := json.Marshal(dataExportFromDatabase(export))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(dat)
+	case "failed":
+		respondWithError(w, http.StatusInternalServerError, "Export failed, please request a new one")
+	default:
+		if export.ExpiresAt.Valid && export.ExpiresAt.Time.Before(time.Now()) {
+			respondWithError(w, http.StatusGone, "Export has expired, please request a new one")
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, export.ID))
+		w.WriteHeader(http.StatusOK)
+		w.Write(export.Archive)
+	}
+}
+
+// buildDataExport waits for a free export slot, so a burst of requests
+// queues up instead of loading every archive into memory at once.
+func (cfg *apiConfig) buildDataExport(ctx context.Context, exportID, userID uuid.UUID) {
+	cfg.dataExportSlots <- struct{}{}
+	defer func() { <-cfg.dataExportSlots }()
+
+	archive, err := cfg.collectDataExport(ctx, userID)
+	if err != nil {
+		fmt.Println(err)
+		// Failed exports expire too, so they are cleaned up with the rest.
+		err = cfg.db.FailDataExport(ctx, database.FailDataExportParams{
+			FailureReason: sql.NullString{String: err.Error(), Valid: true},
+			ExpiresAt:     sql.NullTime{Time: time.Now().Add(cfg.dataExportTTL), Valid: true},
+			ID:            exportID,
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	err = cfg.db.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		Archive:   archive,
+		ExpiresAt: sql.NullTime{Time: time.Now().Add(cfg.dataExportTTL), Valid: true},
+		ID:        exportID,
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// collectDataExport gathers everything stored about a user. New tables that
+// hold personal data should add a section here.
+func (cfg *apiConfig) collectDataExport(ctx context.Context, userID uuid.UUID) ([]byte, error) {
+	type session struct {
+		TokenHint string     `json:"token_hint"`
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+	type chirpyRed struct {
+		IsChirpyRed  bool                       `json:"is_chirpy_red"`
+		Subscription *Subscription              `json:"subscription"`
+		History      []SubscriptionHistoryEntry `json:"history"`
+	}
+
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile := struct {
+		User
+		Role             string     `json:"role"`
+		SensitiveContent string     `json:"sensitive_content"`
+		SuspendedUntil   *time.Time `json:"suspended_until"`
+		SuspensionReason *string    `json:"suspension_reason"`
+		ShadowBanned     bool       `json:"shadow_banned"`
+	}{
+		User: User{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+		},
+		Role:             user.Role,
+		SensitiveContent: user.SensitiveContent,
+		ShadowBanned:     user.ShadowBanned,
+	}
+	if user.SuspendedUntil.Valid {
+		profile.SuspendedUntil = &user.SuspendedUntil.Time
+	}
+	if user.SuspensionReason.Valid {
+		profile.SuspensionReason = &user.SuspensionReason.String
+	}
+
+	dbChirps, err := cfg.db.GetAllChirpsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps := []Chirp{}
+	for _, chirp := range dbChirps {
+		chirps = append(chirps, chirpFromDatabase(chirp))
+	}
+
+	// Refresh tokens are live credentials, so only a hint of each is exported.
+	tokens, err := cfg.db.GetRefreshTokensByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []session{}
+	for _, token := range tokens {
+		s := session{
+			TokenHint: "..." + token.Token[len(token.Token)-4:],
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			s.RevokedAt = &token.RevokedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+
+	credentials, err := cfg.db.GetPasskeyCredentialsByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys := []Passkey{}
+	for _, credential := range credentials {
+		passkeys = append(passkeys, passkeyFromDatabase(credential))
+	}
+
+	dbReports, err := cfg.db.GetReportsByReporterID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	reports := []Report{}
+	for _, report := range dbReports {
+		reports = append(reports, reportFromDatabase(report))
+	}
+
+	blocks, err := cfg.db.GetUserBlocks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mutes, err := cfg.db.GetUserMutes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	red := chirpyRed{IsChirpyRed: user.IsChirpyRed}
+	subscription, err := cfg.db.GetSubscriptionByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		s := subscriptionFromDatabase(subscription)
+		red.Subscription = &s
+	}
+	history, err := cfg.db.GetSubscriptionHistoryByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	red.History = subscriptionHistoryFromDatabase(history)
+
+	return dataexport.Build(time.Now(), []dataexport.Section{
+		{Name: "profile", Title: "Profile", Data: profile},
+		{Name: "chirps", Title: "Chirps", Data: chirps},
+		{Name: "sessions", Title: "Sessions", Data: sessions},
+		{Name: "passkeys", Title: "Passkeys", Data: passkeys},
+		{Name: "reports", Title: "Reports you filed", Data: reports},
+		{Name: "blocks", Title: "Blocked users", Data: blockedUsersFromDatabase(blocks)},
+		{Name: "mutes", Title: "Muted users", Data: mutedUsersFromDatabase(mutes)},
+		{Name: "chirpy_red", Title: "Chirpy Red", Data: red},
+	})
+}
+
+// deleteExpiredDataExports fails exports that have been pending past their
+// deadline, then deletes expired archives and failures.
+func (cfg *apiConfig) deleteExpiredDataExports(ctx context.Context) {
+	failed, err := cfg.db.FailStaleDataExports(ctx, sql.NullTime{Time: time.Now().Add(cfg.dataExportTTL), Valid: true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if failed > 0 {
+		fmt.Printf("failed %d interrupted data exports\n", failed)
+	}
+
+	deleted, err := cfg.db.DeleteExpiredDataExports(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if deleted > 0 {
+		fmt.Printf("deleted %d expired data exports\n", deleted)
+	}
+}
+
+func dataExportFromDatabase(export database.DataExport) DataExport {
+	res := DataExport{
+		ID:        export.ID,
+		CreatedAt: export.CreatedAt,
+		Status:    export.Status,
+	}
+	// A pending export's expiry is its build deadline, which is ours to
+	// know rather than the user's.
+	if export.ExpiresAt.Valid && export.Status != "pending" {
+		res.ExpiresAt = &export.ExpiresAt.Time
+	}
+	return res
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+)
+
+// loadPlans reads plan capabilities from ENTITLEMENTS_FILE, falling back to
+// the defaults. RATE_LIMIT_RED_MULTIPLIER still overrides the Chirpy Red
+// rate limit multiplier.
+func loadPlans() entitlements.Plans {
+	plans := entitlements.Default()
+	if path := os.Getenv("ENTITLEMENTS_FILE"); path != "" {
+		loaded, err := entitlements.Load(path)
+		if err != nil {
+			fmt.Println(err)
+		} else {
+			plans = loaded
+		}
+	}
+	if multiplier, err := strconv.ParseFloat(os.Getenv("RATE_LIMIT_RED_MULTIPLIER"), 64); err == nil && multiplier > 0 {
+		red := plans[entitlements.PlanChirpyRed]
+		red.RateLimitMultiplier = multiplier
+		plans[entitlements.PlanChirpyRed] = red
+	}
+	return plans
+}
+
+// userPlan returns the plan a user is on. Chirpy Red members without a
+// subscription record, who were upgraded before subscriptions were tracked,
+// are on the Chirpy Red plan.
+func (cfg *apiConfig) userPlan(ctx context.Context, user database.User) (string, error) {
+	if !user.IsChirpyRed {
+		return entitlements.PlanFree, nil
+	}
+	subscription, err := cfg.db.GetSubscriptionByUserID(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return entitlements.PlanChirpyRed, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return subscription.Plan, nil
+}
+
+// capabilities looks up what a user's plan allows. It is read from the
+// database on each call so a plan change applies straight away.
+func (cfg *apiConfig) capabilities(ctx context.Context, userID uuid.UUID) (entitlements.Capabilities, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Capabilities{}, err
+	}
+	plan, err := cfg.userPlan(ctx, user)
+	if err != nil {
+		return entitlements.Capabilities{}, err
+	}
+	return cfg.plans.For(plan), nil
+}
+
+func (cfg *apiConfig) getEntitlements(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		Plan string `json:"plan"`
+		entitlements.Capabilities
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	plan, err := cfg.userPlan(req.Context(), user)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(response{Plan: plan, Capabilities: cfg.plans.For(plan)})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
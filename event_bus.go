@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/events"
+	"github.com/ifeanyibatman/chirpy/internal/stream"
+)
+
+// Topics on the event bus. Everything a replica needs to hear about from
+// the others goes through the bus rather than straight to the code that
+// reacts to it.
+const (
+	topicChirpCreated       = "chirp.created"
+	topicChirpDeleted       = "chirp.deleted"
+	topicBannedWordsChanged = "banned_words.changed"
+	topicWebhooksPending    = "webhooks.pending"
+)
+
+// loadEventBus returns the bus chosen by EVENT_BUS: "memory" for a single
+// instance, the default, or "postgres" to share events between replicas
+// over LISTEN/NOTIFY on DB_URL.
+func loadEventBus(db *sql.DB, dbURL string) (events.Bus, error) {
+	switch bus := os.Getenv("EVENT_BUS"); bus {
+	case "", "memory":
+		return events.NewMemory(), nil
+	case "postgres":
+		return events.NewPostgres(db, dbURL)
+	default:
+		return nil, fmt.Errorf("unknown EVENT_BUS %q, expected memory or postgres", bus)
+	}
+}
+
+func (cfg *apiConfig) publishEvent(ctx context.Context, topic string, payload any) {
+	if err := cfg.events.Publish(ctx, topic, payload); err != nil {
+		fmt.Println(fmt.Errorf("publishing %s: %w", topic, err))
+	}
+}
+
+// subscribeEvents connects the bus to the code that reacts to its events on
+// this replica.
+func (cfg *apiConfig) subscribeEvents() {
+	cfg.events.Subscribe(topicChirpCreated, cfg.chirpCreatedHandler)
+	cfg.events.Subscribe(topicChirpDeleted, cfg.chirpDeletedHandler)
+
+	// The replica that changed the word list has already reloaded it, but
+	// reloading again is cheap and keeps this simple.
+	cfg.events.Subscribe(topicBannedWordsChanged, func(payload []byte) {
+		go func() {
+			if err := cfg.reloadProfanityFilter(context.Background()); err != nil {
+				fmt.Println(err)
+			}
+		}()
+	})
+
+	cfg.events.Subscribe(topicWebhooksPending, func(payload []byte) {
+		select {
+		case cfg.webhooksPending <- struct{}{}:
+		default:
+		}
+	})
+}
+
+// chirpEvent is the payload on the chirp topics. NOTIFY payloads are
+// limited to 8000 bytes, so chirps travel by ID and subscribers load them.
+type chirpEvent struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// chirpCreatedHandler loads an announced chirp for the live streams. One
+// that was deleted or withdrawn before it got here is skipped; its removal
+// follows on the other topic.
+func (cfg *apiConfig) chirpCreatedHandler(payload []byte) {
+	var event chirpEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		fmt.Println(err)
+		return
+	}
+	chirp, err := cfg.db.GetChirpByIDAnyStatus(context.Background(), event.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if !chirp.AnnouncedAt.Valid {
+		return
+	}
+	cfg.chirpStream.Publish(chirpStreamEvent(streamChirpCreated, chirp))
+}
+
+// chirpDeletedHandler passes a removal on to the live streams. The chirp
+// may be gone from the database, so only its IDs are known.
+func (cfg *apiConfig) chirpDeletedHandler(payload []byte) {
+	var event chirpEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		fmt.Println(err)
+		return
+	}
+	cfg.chirpStream.Publish(stream.Event{
+		Type:     streamChirpDeleted,
+		AuthorID: event.UserID,
+		ThreadID: event.ID,
+		Removal:  true,
+		Payload:  database.Chirp{ID: event.ID, UserID: event.UserID},
+	})
+}
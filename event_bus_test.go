@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/events"
+	"github.com/ifeanyibatman/chirpy/internal/stream"
+)
+
+// recordingBus remembers the payloads published on it before passing them
+// on.
+type recordingBus struct {
+	*events.Memory
+	published map[string][]any
+}
+
+func (b *recordingBus) Publish(ctx context.Context, topic string, payload any) error {
+	b.published[topic] = append(b.published[topic], payload)
+	return b.Memory.Publish(ctx, topic, payload)
+}
+
+func TestChirpEventsCarryIDs(t *testing.T) {
+	chirp := database.Chirp{
+		ID:               uuid.New(),
+		UserID:           uuid.New(),
+		Body:             "hello #chirpy",
+		ModerationStatus: "visible",
+		AnnouncedAt:      sql.NullTime{Time: time.Now(), Valid: true},
+	}
+
+	tests := []struct {
+		name string
+		// stored is the chirp the created handler loads, if any.
+		stored *database.Chirp
+		want   []string
+	}{
+		{name: "announced", stored: &chirp, want: []string{streamChirpCreated, streamChirpDeleted}},
+		{name: "gone before it was loaded", want: []string{streamChirpDeleted}},
+		{name: "withdrawn before it was loaded", stored: &database.Chirp{ID: chirp.ID, UserID: chirp.UserID}, want: []string{streamChirpDeleted}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, queries := newFakeDB(t)
+			bus := &recordingBus{Memory: events.NewMemory(), published: map[string][]any{}}
+			cfg := &apiConfig{db: queries, events: bus, chirpStream: stream.NewBroker(10, 10)}
+			cfg.subscribeEvents()
+
+			fake.handle("GetChirpByIDAnyStatus", func(args []driver.Value) ([][]driver.Value, int64, error) {
+				if tc.stored == nil {
+					return nil, 0, nil
+				}
+				return [][]driver.Value{fakeRow(*tc.stored)}, 0, nil
+			})
+
+			// Hashtag subscribers get the removal even though the deleted
+			// chirp's body isn't known any more.
+			sub, _, _ := cfg.chirpStream.Subscribe(stream.Filter{Hashtag: "chirpy"}, 0)
+			defer sub.Close()
+
+			cfg.publishEvent(context.Background(), topicChirpCreated, chirpEvent{ID: chirp.ID, UserID: chirp.UserID})
+			cfg.publishChirpDeleted(context.Background(), chirp)
+
+			for topic, payloads := range bus.published {
+				for _, payload := range payloads {
+					if _, ok := payload.(chirpEvent); !ok {
+						t.Errorf("%s carried %T, want chirpEvent", topic, payload)
+					}
+				}
+			}
+
+			var got []string
+			for len(sub.Events) > 0 {
+				event := <-sub.Events
+				got = append(got, event.Type)
+				if event.ThreadID != chirp.ID || event.AuthorID != chirp.UserID {
+					t.Errorf("%s event for chirp %s by %s", event.Type, event.ThreadID, event.AuthorID)
+				}
+				if event.Type == streamChirpCreated && event.Payload.(database.Chirp).Body != chirp.Body {
+					t.Errorf("created event carries %+v, want the stored chirp", event.Payload)
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("streamed %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// fakeQuery answers one sqlc query. It returns the result rows for queries
+// and the number of affected rows for statements.
+type fakeQuery func(args []driver.Value) (rows [][]driver.Value, affected int64, err error)
+
+// fakeDB is a database/sql driver for handler tests. Each sqlc query is
+// answered by name from a function the test registers, so handlers run the
+// real generated code without a Postgres server. Queries are answered one
+// at a time.
+type fakeDB struct {
+	t       *testing.T
+	mu      sync.Mutex
+	queries map[string]fakeQuery
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *database.Queries) {
+	f := &fakeDB{t: t, queries: map[string]fakeQuery{}}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { db.Close() })
+	return f, database.New(db)
+}
+
+func (f *fakeDB) handle(name string, query fakeQuery) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.queries[name] = query
+}
+
+var queryNamePattern = regexp.MustCompile(`-- name: (\w+)`)
+
+func (f *fakeDB) run(query string, args []driver.Value) ([][]driver.Value, int64, error) {
+	match := queryNamePattern.FindStringSubmatch(query)
+	if match == nil {
+		return nil, 0, fmt.Errorf("fakeDB: query without a name: %s", query)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	handler, ok := f.queries[match[1]]
+	if !ok {
+		f.t.Errorf("fakeDB: unexpected query %s", match[1])
+		return nil, 0, fmt.Errorf("fakeDB: unexpected query %s", match[1])
+	}
+	return handler(args)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+// fakeTx lets code that uses transactions run, but doesn't roll anything
+// back.
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, affected, err := s.db.run(s.query, args)
+	return driver.RowsAffected(affected), err
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.db.run(s.query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// fakeRow turns a generated model into a result row, one column per field.
+func fakeRow(model any) []driver.Value {
+	v := reflect.ValueOf(model)
+	row := make([]driver.Value, v.NumField())
+	for i := range row {
+		field := v.Field(i).Interface()
+		if valuer, ok := field.(driver.Valuer); ok {
+			value, err := valuer.Value()
+			if err != nil {
+				panic(err)
+			}
+			row[i] = value
+			continue
+		}
+		if values, ok := field.([]string); ok {
+			row[i], _ = pq.StringArray(values).Value()
+			continue
+		}
+		switch value := v.Field(i); value.Kind() {
+		case reflect.Slice:
+			row[i] = value.Bytes()
+		case reflect.Int32, reflect.Int64:
+			row[i] = value.Int()
+		default:
+			row[i] = field
+		}
+	}
+	return row
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -29,14 +30,22 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	regClaims := jwt.RegisteredClaims{
-		Issuer:    "Chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
-		Subject:   userID.String(),
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+func MakeJWT(userID uuid.UUID, role string, tokenSecret string, expiresIn time.Duration) (string, error) {
+	claims := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "Chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, regClaims)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	signedToken, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
 		return "", err
@@ -45,24 +54,35 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
+	id, _, err := ValidateJWTWithRole(tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTWithRole also returns the role the token was issued with.
+// Tokens issued before roles existed are treated as plain users.
+func ValidateJWTWithRole(tokenString, tokenSecret string) (uuid.UUID, string, error) {
+	claims := &Claims{}
 	token , err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error){
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
+	}
+	role := claims.Role
+	if role == "" {
+		role = RoleUser
 	}
-	return id, nil
+	return id, role, nil
 
 }
 
@@ -91,4 +111,17 @@ func MakeRefreshToken() (string, error) {
 		return "", errors.New("X-API-Key header is missing")
 	}
 	return strings.TrimSpace(apiKey), nil
- }
\ No newline at end of file
+ }
+
+// HashToken returns the hex SHA-256 of a single-use token so only the hash
+// needs to be stored.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DeviceFingerprint hashes the request headers that stay stable for one
+// browser, so a token can be bound to the device that asked for it.
+func DeviceFingerprint(headers http.Header) string {
+	return HashToken(headers.Get("User-Agent") + "\n" + headers.Get("Accept-Language"))
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// PasswordPolicy is what a new password must satisfy. MinLength counts
+// characters, but MaxLength counts bytes, since it bounds the input to
+// argon2.
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	DisallowEmail bool
+	Breached      *BreachedPasswords
+}
+
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     128,
+		DisallowEmail: true,
+	}
+}
+
+// Validate returns every rule the password breaks. An empty slice means the
+// password is acceptable.
+func (p PasswordPolicy) Validate(password, email string) []PasswordViolation {
+	violations := []PasswordViolation{}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    "min_length",
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("Password must be at most %d bytes", p.MaxLength),
+		})
+	}
+	if p.DisallowEmail && email != "" {
+		localPart, _, _ := strings.Cut(email, "@")
+		if strings.EqualFold(password, email) || strings.EqualFold(password, localPart) {
+			violations = append(violations, PasswordViolation{
+				Rule:    "email",
+				Message: "Password must not be your email address",
+			})
+		}
+	}
+	if p.Breached != nil && password != "" && p.Breached.Contains(password) {
+		violations = append(violations, PasswordViolation{
+			Rule:    "breached",
+			Message: "Password has appeared in a known data breach",
+		})
+	}
+	return violations
+}
+
+// BreachedPasswords holds SHA-1 hashes of known breached passwords, bucketed
+// by their first five hex characters the same way the k-anonymity range API
+// of Have I Been Pwned serves them.
+type BreachedPasswords struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswords reads a file of upper-case SHA-1 hashes, one per line,
+// optionally followed by ":count" as in the downloadable Pwned Passwords list.
+func LoadBreachedPasswords(path string) (*BreachedPasswords, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	breached := &BreachedPasswords{ranges: map[string]map[string]struct{}{}}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != 40 {
+			return nil, fmt.Errorf("invalid SHA-1 hash in %s: %q", path, hash)
+		}
+		prefix, suffix := hash[:5], hash[5:]
+		if breached.ranges[prefix] == nil {
+			breached.ranges[prefix] = map[string]struct{}{}
+		}
+		breached.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return breached, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, ok := b.ranges[hash[:5]]
+	if !ok {
+		return false
+	}
+	_, ok = suffixes[hash[5:]]
+	return ok
+}
@@ -0,0 +1,38 @@
+package auth
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func IsValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether a user holding role is allowed to act as required.
+// Roles are hierarchical, so admins can do everything moderators can.
+func HasRole(role, required string) bool {
+	have, ok := roleRank[role]
+	if !ok {
+		return false
+	}
+	return have >= roleRank[required]
+}
+
+// Outranks reports whether role ranks strictly above other, which is what it
+// takes to moderate a user holding other.
+func Outranks(role, other string) bool {
+	have, ok := roleRank[role]
+	if !ok {
+		return false
+	}
+	return have > roleRank[other]
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"event":"user.upgraded"}`)
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name       string
+		signedAt   time.Time
+		timestamp  string
+		signatures func(signedAt time.Time) string
+		secrets    []string
+		want       error
+	}{
+		{
+			name:       "valid",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+		},
+		{
+			name:       "just inside the tolerance",
+			signedAt:   now.Add(-tolerance),
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+		},
+		{
+			name:       "too old",
+			signedAt:   now.Add(-tolerance - time.Second),
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrStaleWebhookTimestamp,
+		},
+		{
+			name:       "too far in the future",
+			signedAt:   now.Add(tolerance + time.Second),
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrStaleWebhookTimestamp,
+		},
+		{
+			name:       "wrong secret",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return SignWebhook("stolen", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrInvalidWebhookSignature,
+		},
+		{
+			name:       "signed with the previous secret",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return SignWebhook("previous", at, body) },
+			secrets:    []string{"current", "previous"},
+		},
+		{
+			name:     "sender sends both secrets",
+			signedAt: now,
+			signatures: func(at time.Time) string {
+				return SignWebhook("previous", at, body) + ", " + SignWebhook("current", at, body)
+			},
+			secrets: []string{"current"},
+		},
+		{
+			name:       "retired secret",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return SignWebhook("previous", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrInvalidWebhookSignature,
+		},
+		{
+			name:       "timestamp changed after signing",
+			signedAt:   now,
+			timestamp:  strconv.FormatInt(now.Add(time.Second).Unix(), 10),
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrInvalidWebhookSignature,
+		},
+		{
+			name:       "unknown version",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return "v2" + SignWebhook("current", at, body)[2:] },
+			secrets:    []string{"current"},
+			want:       ErrInvalidWebhookSignature,
+		},
+		{
+			name:       "not hex",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return "v1=nothex" },
+			secrets:    []string{"current"},
+			want:       ErrInvalidWebhookSignature,
+		},
+		{
+			name:       "missing signature",
+			signedAt:   now,
+			signatures: func(at time.Time) string { return "" },
+			secrets:    []string{"current"},
+			want:       ErrMissingWebhookSignature,
+		},
+		{
+			name:       "malformed timestamp",
+			signedAt:   now,
+			timestamp:  "yesterday",
+			signatures: func(at time.Time) string { return SignWebhook("current", at, body) },
+			secrets:    []string{"current"},
+			want:       ErrMissingWebhookSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamp := tt.timestamp
+			if timestamp == "" {
+				timestamp = strconv.FormatInt(tt.signedAt.Unix(), 10)
+			}
+			headers := http.Header{}
+			headers.Set(WebhookTimestampHeader, timestamp)
+			headers.Set(WebhookSignatureHeader, tt.signatures(tt.signedAt))
+
+			err := VerifyWebhookSignature(headers, body, tt.secrets, now, tolerance)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifyWebhookSignature() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyWebhookSignatureBody(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	headers := http.Header{}
+	headers.Set(WebhookTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	headers.Set(WebhookSignatureHeader, SignWebhook("current", now, []byte(`{"amount":1}`)))
+
+	err := VerifyWebhookSignature(headers, []byte(`{"amount":100}`), []string{"current"}, now, time.Minute)
+	if !errors.Is(err, ErrInvalidWebhookSignature) {
+		t.Errorf("VerifyWebhookSignature() error = %v, want ErrInvalidWebhookSignature", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Package billing turns payment provider webhooks into provider-neutral
+// subscription events. Each provider gets an adapter implementing Provider;
+// everything after parsing is shared.
+package billing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType is what happened to a subscription, in Chirpy's terms.
+type EventType string
+
+const (
+	// EventIgnored is for provider events Chirpy doesn't act on.
+	EventIgnored EventType = ""
+	// EventStarted starts a new subscription.
+	EventStarted EventType = "started"
+	// EventRenewed starts the next paid period.
+	EventRenewed EventType = "renewed"
+	// EventPaymentFailed means a renewal payment didn't go through. The
+	// subscription lasts until its period ends.
+	EventPaymentFailed EventType = "payment_failed"
+	// EventCancelled stops renewals. The subscription lasts until its
+	// period ends.
+	EventCancelled EventType = "cancelled"
+	// EventEnded ends the subscription immediately.
+	EventEnded EventType = "ended"
+)
+
+var (
+	// ErrUnknownCustomer means an event names a customer with no Chirpy
+	// user.
+	ErrUnknownCustomer = errors.New("billing event names an unknown customer")
+	// ErrInvalidPayload means a request body couldn't be parsed.
+	ErrInvalidPayload = errors.New("billing event payload is invalid")
+)
+
+// Event is a provider webhook translated into Chirpy's terms.
+type Event struct {
+	// ID is the provider's ID for the event, the same across retries.
+	ID   string
+	Type EventType
+	// ProviderType is the provider's own name for the event.
+	ProviderType string
+	// CustomerID is the provider's reference to the customer, mapped to a
+	// user with Provider.UserID.
+	CustomerID string
+	// Plan and the period bounds are optional; zero values leave the choice
+	// to the caller.
+	Plan        string
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+}
+
+type Provider interface {
+	// Name identifies the provider in stored events and subscription
+	// history. It must not change once events have been stored.
+	Name() string
+	// Verify checks that a webhook request came from the provider. body is
+	// the raw request body.
+	Verify(header http.Header, body []byte, now time.Time) error
+	// Parse translates a verified webhook. header is empty when a stored
+	// event is replayed, so Parse must not need it for anything but the
+	// event ID.
+	Parse(header http.Header, body []byte) (Event, error)
+	// UserID maps a customer to a Chirpy user, or returns
+	// ErrUnknownCustomer.
+	UserID(ctx context.Context, customerID string) (uuid.UUID, error)
+}
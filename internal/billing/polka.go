@@ -0,0 +1,94 @@
+package billing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+)
+
+// polkaEventIDHeader carries Polka's ID for an event. It stays the same
+// across retries of one delivery.
+const polkaEventIDHeader = "X-Webhook-Id"
+
+var polkaEvents = map[string]EventType{
+	"user.upgraded":          EventStarted,
+	"subscription.renewed":   EventRenewed,
+	"payment.failed":         EventPaymentFailed,
+	"subscription.cancelled": EventCancelled,
+	"user.downgraded":        EventEnded,
+}
+
+// Polka receives webhooks signed with auth.SignWebhook. Polka customers are
+// referred to by their Chirpy user ID.
+type Polka struct {
+	// Secrets are the signing secrets currently accepted. Several can be
+	// active while one is being rotated.
+	Secrets []string
+	// Tolerance is how far a signed timestamp may be from our clock.
+	Tolerance time.Duration
+}
+
+func (p Polka) Name() string {
+	return "polka"
+}
+
+func (p Polka) Verify(header http.Header, body []byte, now time.Time) error {
+	return auth.VerifyWebhookSignature(header, body, p.Secrets, now, p.Tolerance)
+}
+
+func (p Polka) Parse(header http.Header, body []byte) (Event, error) {
+	type polkaWebhook struct {
+		ID    string `json:"id"`
+		Event string `json:"event"`
+		Data  struct {
+			UserID      string    `json:"user_id"`
+			Plan        string    `json:"plan"`
+			PeriodStart time.Time `json:"period_start"`
+			PeriodEnd   time.Time `json:"period_end"`
+		} `json:"data"`
+	}
+
+	webhook := polkaWebhook{}
+	err := json.Unmarshal(body, &webhook)
+	if err != nil {
+		return Event{}, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	return Event{
+		ID:           polkaEventID(header, webhook.ID, body),
+		Type:         polkaEvents[webhook.Event],
+		ProviderType: webhook.Event,
+		CustomerID:   webhook.Data.UserID,
+		Plan:         webhook.Data.Plan,
+		PeriodStart:  webhook.Data.PeriodStart,
+		PeriodEnd:    webhook.Data.PeriodEnd,
+	}, nil
+}
+
+func (p Polka) UserID(ctx context.Context, customerID string) (uuid.UUID, error) {
+	userID, err := uuid.Parse(customerID)
+	if err != nil {
+		return uuid.Nil, ErrUnknownCustomer
+	}
+	return userID, nil
+}
+
+// polkaEventID picks the ID retries of an event share: the delivery header,
+// then the id in the payload, then a hash of the payload itself.
+func polkaEventID(header http.Header, id string, body []byte) string {
+	if id := strings.TrimSpace(header.Get(polkaEventIDHeader)); id != "" {
+		return id
+	}
+	if id != "" {
+		return id
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -7,21 +7,101 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const announceChirp = `-- name: AnnounceChirp :one
+UPDATE chirps SET announced_at = NOW()
+WHERE id = $1 AND announced_at IS NULL AND created_at <= NOW() AND moderation_status IN ('visible', 'flagged')
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+func (q *Queries) AnnounceChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, announceChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const announceDueChirps = `-- name: AnnounceDueChirps :many
+UPDATE chirps SET announced_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE announced_at IS NULL AND created_at <= NOW() AND moderation_status IN ('visible', 'flagged')
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+func (q *Queries) AnnounceDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, announceDueChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id) VALUES (gen_random_uuid(),NOW(),NOW(),$1,$2) RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive)
+VALUES (gen_random_uuid(), COALESCE($1::timestamp, NOW()), NOW(), $2, $3, $4, $5, $6)
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	PublishAt        sql.NullTime
+	Body             string
+	UserID           uuid.UUID
+	ModerationStatus string
+	ContentWarning   sql.NullString
+	Sensitive        bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.PublishAt, arg.Body, arg.UserID, arg.ModerationStatus, arg.ContentWarning, arg.Sensitive)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -29,6 +109,12 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
 	)
 	return i, err
 }
@@ -51,12 +137,133 @@ func (q *Queries) DeleteChirps(ctx context.Context) error {
 	return err
 }
 
+const deleteChirpsOfPurgedUsers = `-- name: DeleteChirpsOfPurgedUsers :many
+DELETE FROM chirps
+WHERE user_id IN (
+    SELECT id FROM users
+    WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at < $1::timestamp
+)
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+func (q *Queries) DeleteChirpsOfPurgedUsers(ctx context.Context, cutoff time.Time) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, deleteChirpsOfPurgedUsers, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllChirpsByUserID = `-- name: GetAllChirpsByUserID :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+`
+
+func (q *Queries) GetAllChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirpsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.moderation_status, chirps.content_warning, chirps.sensitive, chirps.edited_at, chirps.pinned_at, chirps.announced_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = $1 AND users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = $2))
+  AND (NOT users.shadow_banned OR users.id = $2)
+  AND (chirps.created_at <= NOW() OR users.id = $2)
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = $2 AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = $2)
+  )
+`
+
+type GetChirpByIDParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpByID(ctx context.Context, arg GetChirpByIDParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const getChirpByIDAnyStatus = `-- name: GetChirpByIDAnyStatus :one
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps WHERE id = $1
 `
 
-func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+func (q *Queries) GetChirpByIDAnyStatus(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDAnyStatus, id)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -64,16 +271,76 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.moderation_status, chirps.content_warning, chirps.sensitive, chirps.edited_at, chirps.pinned_at, chirps.announced_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = $1))
+  AND (NOT users.shadow_banned OR users.id = $1)
+  AND (chirps.created_at <= NOW() OR users.id = $1)
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = $1 AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = $1)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = $1 AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at ASC
+`
+
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByModerationStatus = `-- name: GetChirpsByModerationStatus :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps WHERE moderation_status = $1 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpsByModerationStatus(ctx context.Context, moderationStatus string) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByModerationStatus, moderationStatus)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +354,12 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -102,11 +375,77 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserID = `-- name: GetChirpsByUserID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.moderation_status, chirps.content_warning, chirps.sensitive, chirps.edited_at, chirps.pinned_at, chirps.announced_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1 AND users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = $2))
+  AND (NOT users.shadow_banned OR users.id = $2)
+  AND (chirps.created_at <= NOW() OR users.id = $2)
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = $2 AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = $2)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = $2 AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at ASC
+`
+
+type GetChirpsByUserIDParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetChirpsByUserID(ctx context.Context, arg GetChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByUserIDSince = `-- name: GetChirpsByUserIDSince :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps
+WHERE user_id = $1 AND created_at > $2::timestamp
+ORDER BY created_at DESC
 `
 
-func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserID, userID)
+type GetChirpsByUserIDSinceParams struct {
+	UserID uuid.UUID
+	Since  time.Time
+}
+
+func (q *Queries) GetChirpsByUserIDSince(ctx context.Context, arg GetChirpsByUserIDSinceParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserIDSince, arg.UserID, arg.Since)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +459,12 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -135,11 +480,118 @@ func (q *Queries) GetChirpsByUserID(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const getChirpsDesc = `-- name: GetChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at DESC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.moderation_status, chirps.content_warning, chirps.sensitive, chirps.edited_at, chirps.pinned_at, chirps.announced_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = $1))
+  AND (NOT users.shadow_banned OR users.id = $1)
+  AND (chirps.created_at <= NOW() OR users.id = $1)
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = $1 AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = $1)
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = $1 AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at DESC
+`
+
+func (q *Queries) GetChirpsDesc(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsDesc, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsPage = `-- name: GetChirpsPage :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps
+WHERE (created_at, id) > ($1::timestamp, $2::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT $3::int
+`
+
+type GetChirpsPageParams struct {
+	AfterCreatedAt time.Time
+	AfterID        uuid.UUID
+	PageSize       int32
+}
+
+func (q *Queries) GetChirpsPage(ctx context.Context, arg GetChirpsPageParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsPage, arg.AfterCreatedAt, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRecentChirpsByUserID = `-- name: GetRecentChirpsByUserID :many
+SELECT id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at FROM chirps WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2
 `
 
-func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsDesc)
+type GetRecentChirpsByUserIDParams struct {
+	UserID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentChirpsByUserID(ctx context.Context, arg GetRecentChirpsByUserIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentChirpsByUserID, arg.UserID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +605,12 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ModerationStatus,
+			&i.ContentWarning,
+			&i.Sensitive,
+			&i.EditedAt,
+			&i.PinnedAt,
+			&i.AnnouncedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -166,3 +624,177 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const lockUser = `-- name: LockUser :exec
+SELECT id FROM users WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockUser, id)
+	return err
+}
+
+const pinChirp = `-- name: PinChirp :one
+UPDATE chirps SET pinned_at = NOW()
+WHERE id = $1
+  AND (SELECT COUNT(*) FROM chirps pinned WHERE pinned.user_id = chirps.user_id AND pinned.pinned_at IS NOT NULL) < $2::int
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+type PinChirpParams struct {
+	ID        uuid.UUID
+	MaxPinned int32
+}
+
+func (q *Queries) PinChirp(ctx context.Context, arg PinChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, pinChirp, arg.ID, arg.MaxPinned)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const unpinChirp = `-- name: UnpinChirp :one
+UPDATE chirps SET pinned_at = NULL WHERE id = $1 RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+func (q *Queries) UnpinChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unpinChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, moderation_status = $2, edited_at = NOW(), updated_at = NOW() WHERE id = $3 RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body             string
+	ModerationStatus string
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ModerationStatus, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const updateChirpLabels = `-- name: UpdateChirpLabels :one
+UPDATE chirps SET content_warning = $1, sensitive = $2, updated_at = NOW() WHERE id = $3 RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+type UpdateChirpLabelsParams struct {
+	ContentWarning sql.NullString
+	Sensitive      bool
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateChirpLabels(ctx context.Context, arg UpdateChirpLabelsParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpLabels, arg.ContentWarning, arg.Sensitive, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const updateChirpModerationStatus = `-- name: UpdateChirpModerationStatus :one
+UPDATE chirps SET moderation_status = $1, updated_at = NOW() WHERE id = $2 RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+type UpdateChirpModerationStatusParams struct {
+	ModerationStatus string
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateChirpModerationStatus(ctx context.Context, arg UpdateChirpModerationStatusParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpModerationStatus, arg.ModerationStatus, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
+
+const withdrawChirp = `-- name: WithdrawChirp :one
+UPDATE chirps SET announced_at = NULL
+WHERE id = $1 AND announced_at IS NOT NULL AND moderation_status NOT IN ('visible', 'flagged')
+RETURNING id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive, edited_at, pinned_at, announced_at
+`
+
+func (q *Queries) WithdrawChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, withdrawChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ModerationStatus,
+		&i.ContentWarning,
+		&i.Sensitive,
+		&i.EditedAt,
+		&i.PinnedAt,
+		&i.AnnouncedAt,
+	)
+	return i, err
+}
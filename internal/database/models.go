@@ -6,17 +6,100 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-type Chirp struct {
-	ID        uuid.UUID
+type BannedWord struct {
+	Word      string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
+	Action    string
+}
+
+type Chirp struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Body             string
+	UserID           uuid.UUID
+	ModerationStatus string
+	ContentWarning   sql.NullString
+	Sensitive        bool
+	EditedAt         sql.NullTime
+	PinnedAt         sql.NullTime
+	AnnouncedAt      sql.NullTime
+}
+
+type DataExport struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Status        string
+	Archive       []byte
+	FailureReason sql.NullString
+	ExpiresAt     sql.NullTime
+}
+
+type Gift struct {
+	ID           uuid.UUID
+	RecipientID  uuid.UUID
+	SenderID     uuid.NullUUID
+	Plan         string
+	DurationDays int32
+	Message      string
+	CreatedAt    time.Time
+}
+
+type MagicLink struct {
+	TokenHash       string
+	CreatedAt       time.Time
+	UserID          uuid.UUID
+	FingerprintHash string
+	ExpiresAt       time.Time
+	UsedAt          sql.NullTime
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	ModeratorID  uuid.UUID
+	Action       string
+	ReportID     uuid.NullUUID
+	ChirpID      uuid.NullUUID
+	TargetUserID uuid.NullUUID
+	Note         string
+}
+
+type PasskeyCredential struct {
+	ID         []byte
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Credential json.RawMessage
+	SignCount  int64
+	LastUsedAt sql.NullTime
+}
+
+type PromoCode struct {
+	ID             uuid.UUID
+	Code           string
+	Plan           string
+	DurationDays   int32
+	MaxRedemptions sql.NullInt32
+	Redemptions    int32
+	ExpiresAt      sql.NullTime
+	CreatedBy      uuid.NullUUID
+	CreatedAt      time.Time
+}
+
+type PromoRedemption struct {
+	ID          uuid.UUID
+	PromoCodeID uuid.UUID
+	UserID      uuid.UUID
+	RedeemedAt  time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +111,119 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.NullUUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+	Status     string
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	UserID             uuid.UUID
+	Plan               string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+type SubscriptionHistory struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	UserID         uuid.UUID
+	Event          string
+	Plan           string
+	Status         string
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	Source         string
+	SourceEventID  sql.NullString
+	CreatedAt      time.Time
+}
+
 type User struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Email               string
+	HashedPassword      string
+	IsChirpyRed         bool
+	Role                string
+	DeletionRequestedAt sql.NullTime
+	SuspendedUntil      sql.NullTime
+	SuspensionReason    sql.NullString
+	ShadowBanned        bool
+	SensitiveContent    string
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type WebauthnSession struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.NullUUID
+	Ceremony    string
+	SessionData json.RawMessage
+	ExpiresAt   time.Time
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID
+	EndpointID     uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	DeliveredAt    sql.NullTime
+}
+
+type WebhookEndpoint struct {
+	ID          uuid.UUID
+	Url         string
+	Secret      string
+	Events      []string
+	Description string
+	Active      bool
+	CreatedBy   uuid.NullUUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type WebhookEvent struct {
+	ID          uuid.UUID
+	Source      string
+	EventID     string
+	EventType   string
+	Payload     []byte
+	ReceivedAt  time.Time
+	Status      string
+	Error       sql.NullString
+	Attempts    int32
+	ProcessedAt sql.NullTime
+	ClaimedAt   sql.NullTime
 }
@@ -7,12 +7,23 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_requested_at = NULL, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password) VALUES (gen_random_uuid(),NOW(),NOW(),$1, $2) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+INSERT INTO users (id, created_at, updated_at, email, hashed_password) VALUES (gen_random_uuid(),NOW(),NOW(),$1, $2) RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
 `
 
 type CreateUserParams struct {
@@ -30,6 +41,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
 	)
 	return i, err
 }
@@ -44,7 +61,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -57,12 +74,190 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const liftUserSuspension = `-- name: LiftUserSuspension :one
+UPDATE users SET suspended_until = NULL, suspension_reason = NULL, updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+func (q *Queries) LiftUserSuspension(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, liftUserSuspension, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const requestUserDeletion = `-- name: RequestUserDeletion :one
+UPDATE users SET deletion_requested_at = NOW(), updated_at = NOW() WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+func (q *Queries) RequestUserDeletion(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, requestUserDeletion, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const setUserChirpyRed = `-- name: SetUserChirpyRed :one
+UPDATE users SET is_chirpy_red = $1 WHERE id = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+type SetUserChirpyRedParams struct {
+	IsChirpyRed bool
+	ID          uuid.UUID
+}
+
+func (q *Queries) SetUserChirpyRed(ctx context.Context, arg SetUserChirpyRedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserChirpyRed, arg.IsChirpyRed, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const setUserShadowBanned = `-- name: SetUserShadowBanned :one
+UPDATE users SET shadow_banned = $1, updated_at = NOW() WHERE id = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+type SetUserShadowBannedParams struct {
+	ShadowBanned bool
+	ID           uuid.UUID
+}
+
+func (q *Queries) SetUserShadowBanned(ctx context.Context, arg SetUserShadowBannedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserShadowBanned, arg.ShadowBanned, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users SET suspended_until = $1, suspension_reason = $2, updated_at = NOW() WHERE id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   sql.NullTime
+	SuspensionReason sql.NullString
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
 	)
 	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET email = $1, hashed_password = $2 WHERE id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+UPDATE users SET email = $1, hashed_password = $2 WHERE id = $3 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
 `
 
 type UpdateUserParams struct {
@@ -81,16 +276,85 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+type UpdateUserRoleParams struct {
+	Role string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRole, arg.Role, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
 	)
 	return i, err
 }
 
-const upgradeUserToChirpyRed = `-- name: UpgradeUserToChirpyRed :one
-UPDATE users SET is_chirpy_red = TRUE WHERE id = $1 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+const updateUserRoleByEmail = `-- name: UpdateUserRoleByEmail :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE email = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
 `
 
-func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
-	row := q.db.QueryRowContext(ctx, upgradeUserToChirpyRed, id)
+type UpdateUserRoleByEmailParams struct {
+	Role  string
+	Email string
+}
+
+func (q *Queries) UpdateUserRoleByEmail(ctx context.Context, arg UpdateUserRoleByEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserRoleByEmail, arg.Role, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
+	)
+	return i, err
+}
+
+const updateUserSensitiveContent = `-- name: UpdateUserSensitiveContent :one
+UPDATE users SET sensitive_content = $1, updated_at = NOW() WHERE id = $2 RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, role, deletion_requested_at, suspended_until, suspension_reason, shadow_banned, sensitive_content
+`
+
+type UpdateUserSensitiveContentParams struct {
+	SensitiveContent string
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateUserSensitiveContent(ctx context.Context, arg UpdateUserSensitiveContentParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserSensitiveContent, arg.SensitiveContent, arg.ID)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -99,6 +363,12 @@ func (q *Queries) UpgradeUserToChirpyRed(ctx context.Context, id uuid.UUID) (Use
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Role,
+		&i.DeletionRequestedAt,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.ShadowBanned,
+		&i.SensitiveContent,
 	)
 	return i, err
 }
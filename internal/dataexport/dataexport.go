@@ -0,0 +1,141 @@
+// Package dataexport packages everything stored about a user into a ZIP
+// archive: one JSON file per section plus an index.html that renders the same
+// data for people who don't want to read JSON.
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Section struct {
+	// Name is the file name of the section inside the archive, without the
+	// .json extension.
+	Name  string
+	Title string
+	Data  any
+}
+
+func Build(generatedAt time.Time, sections []Section) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	archive := zip.NewWriter(buf)
+
+	index := &strings.Builder{}
+	index.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Your Chirpy data</title>\n")
+	index.WriteString("<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}</style>\n")
+	index.WriteString("</head>\n<body>\n<h1>Your Chirpy data</h1>\n")
+	fmt.Fprintf(index, "<p>Generated %s. Each section is also included as a JSON file.</p>\n<ul>\n", generatedAt.UTC().Format(time.RFC1123))
+	for _, section := range sections {
+		fmt.Fprintf(index, "<li><a href=\"#%s\">%s</a> (<a href=\"%s.json\">%s.json</a>)</li>\n",
+			section.Name, html.EscapeString(section.Title), section.Name, section.Name)
+	}
+	index.WriteString("</ul>\n")
+
+	for _, section := range sections {
+		dat, err := json.MarshalIndent(section.Data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.Name + ".json",
+			Method:   zip.Deflate,
+			Modified: generatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := file.Write(dat); err != nil {
+			return nil, err
+		}
+
+		// Render from the JSON form so the HTML shows exactly what the
+		// JSON file contains.
+		var generic any
+		if err := json.Unmarshal(dat, &generic); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(index, "<h2 id=\"%s\">%s</h2>\n", section.Name, html.EscapeString(section.Title))
+		renderValue(index, generic)
+	}
+	index.WriteString("</body>\n</html>\n")
+
+	file, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     "index.html",
+		Method:   zip.Deflate,
+		Modified: generatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write([]byte(index.String())); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func renderValue(b *strings.Builder, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		b.WriteString("<table>\n")
+		for _, key := range sortedKeys(v) {
+			fmt.Fprintf(b, "<tr><th>%s</th><td>", html.EscapeString(key))
+			renderValue(b, v[key])
+			b.WriteString("</td></tr>\n")
+		}
+		b.WriteString("</table>\n")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("<p><em>None</em></p>\n")
+			return
+		}
+		if rows, ok := v[0].(map[string]any); ok {
+			keys := sortedKeys(rows)
+			b.WriteString("<table>\n<tr>")
+			for _, key := range keys {
+				fmt.Fprintf(b, "<th>%s</th>", html.EscapeString(key))
+			}
+			b.WriteString("</tr>\n")
+			for _, item := range v {
+				row, _ := item.(map[string]any)
+				b.WriteString("<tr>")
+				for _, key := range keys {
+					b.WriteString("<td>")
+					renderValue(b, row[key])
+					b.WriteString("</td>")
+				}
+				b.WriteString("</tr>\n")
+			}
+			b.WriteString("</table>\n")
+			return
+		}
+		b.WriteString("<ul>\n")
+		for _, item := range v {
+			b.WriteString("<li>")
+			renderValue(b, item)
+			b.WriteString("</li>\n")
+		}
+		b.WriteString("</ul>\n")
+	case nil:
+		b.WriteString("<em>none</em>")
+	default:
+		b.WriteString(html.EscapeString(fmt.Sprint(v)))
+	}
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
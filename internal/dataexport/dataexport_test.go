@@ -0,0 +1,128 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	generatedAt := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	type chirp struct {
+		Body string `json:"body"`
+	}
+	sections := []Section{
+		{Name: "profile", Title: "Profile", Data: map[string]any{"email": "a@example.com"}},
+		{Name: "chirps", Title: "Chirps & replies", Data: []chirp{{Body: "<script>alert(1)</script>"}}},
+		{Name: "blocks", Title: "Blocked users", Data: []string{}},
+	}
+
+	dat, err := Build(generatedAt, sections)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	files := readArchive(t, dat)
+
+	for _, name := range []string{"profile.json", "chirps.json", "blocks.json", "index.html"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("archive is missing %s", name)
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("archive has %d files, want 4", len(files))
+	}
+
+	var chirps []chirp
+	if err := json.Unmarshal([]byte(files["chirps.json"]), &chirps); err != nil {
+		t.Fatalf("chirps.json is not valid JSON: %v", err)
+	}
+	if len(chirps) != 1 || chirps[0].Body != "<script>alert(1)</script>" {
+		t.Errorf("chirps.json = %+v, want the original chirp", chirps)
+	}
+
+	index := files["index.html"]
+	for _, want := range []string{
+		"Generated Thu, 01 Jan 2026 12:00:00 UTC",
+		`<a href="#chirps">Chirps &amp; replies</a> (<a href="chirps.json">chirps.json</a>)`,
+		`<h2 id="profile">Profile</h2>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html does not contain %q", want)
+		}
+	}
+	if strings.Contains(index, "<script>") {
+		t.Error("index.html contains an unescaped chirp body")
+	}
+}
+
+func TestBuildMarshalError(t *testing.T) {
+	_, err := Build(time.Now(), []Section{{Name: "bad", Title: "Bad", Data: make(chan int)}})
+	if err == nil {
+		t.Error("Build() accepted data that can't be marshalled")
+	}
+}
+
+func TestRenderValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "scalar", value: `"hello"`, want: "hello"},
+		{name: "number", value: `42`, want: "42"},
+		{name: "null", value: `null`, want: "<em>none</em>"},
+		{name: "escaped", value: `"a < b & c"`, want: "a &lt; b &amp; c"},
+		{
+			name:  "object",
+			value: `{"b": 2, "a": "x"}`,
+			want:  "<table>\n<tr><th>a</th><td>x</td></tr>\n<tr><th>b</th><td>2</td></tr>\n</table>\n",
+		},
+		{name: "empty list", value: `[]`, want: "<p><em>None</em></p>\n"},
+		{name: "list", value: `["x", "y"]`, want: "<ul>\n<li>x</li>\n<li>y</li>\n</ul>\n"},
+		{
+			name:  "list of objects",
+			value: `[{"id": 1, "body": "hi"}, {"id": 2}]`,
+			want:  "<table>\n<tr><th>body</th><th>id</th></tr>\n<tr><td>hi</td><td>1</td></tr>\n<tr><td><em>none</em></td><td>2</td></tr>\n</table>\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value any
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatalf("bad test value: %v", err)
+			}
+			b := &strings.Builder{}
+			renderValue(b, value)
+			if got := b.String(); got != tt.want {
+				t.Errorf("renderValue(%s) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func readArchive(t *testing.T, dat []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(dat), int64(len(dat)))
+	if err != nil {
+		t.Fatalf("Build() did not return a ZIP archive: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatalf("opening %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("reading %s: %v", file.Name, err)
+		}
+		files[file.Name] = string(content)
+	}
+	return files
+}
@@ -0,0 +1,99 @@
+// Package entitlements maps subscription plans to what they let a user do,
+// so perks can be changed in configuration rather than in handlers.
+package entitlements
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	PlanFree      = "free"
+	PlanChirpyRed = "chirpy_red"
+)
+
+type Capabilities struct {
+	// MaxChirpLength is the longest chirp body allowed, in bytes.
+	MaxChirpLength int `json:"max_chirp_length"`
+	// EditChirps allows changing the body of a chirp after posting it.
+	EditChirps bool `json:"edit_chirps"`
+	// RateLimitMultiplier scales every rate limit group.
+	RateLimitMultiplier float64 `json:"rate_limit_multiplier"`
+	// MaxPinnedChirps is how many chirps may be pinned at once. 0 disables
+	// pinning.
+	MaxPinnedChirps int `json:"max_pinned_chirps"`
+	// ScheduledPosts allows posting chirps that are published later.
+	ScheduledPosts bool `json:"scheduled_posts"`
+}
+
+// Plans holds the capabilities of each plan by name.
+type Plans map[string]Capabilities
+
+func Default() Plans {
+	return Plans{
+		PlanFree: {
+			MaxChirpLength:      140,
+			RateLimitMultiplier: 1,
+			MaxPinnedChirps:     1,
+		},
+		PlanChirpyRed: {
+			MaxChirpLength:      280,
+			EditChirps:          true,
+			RateLimitMultiplier: 4,
+			MaxPinnedChirps:     5,
+			ScheduledPosts:      true,
+		},
+	}
+}
+
+// For returns the capabilities of a plan. Unknown plans get the free plan.
+func (p Plans) For(plan string) Capabilities {
+	if capabilities, ok := p[plan]; ok {
+		return capabilities
+	}
+	return p[PlanFree]
+}
+
+// Load reads plans from a JSON object keyed by plan name. A plan in the
+// file starts from the default plan of the same name, or the free plan for
+// new names, so the file only needs the fields it changes.
+func Load(path string) (Plans, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	plans := Default()
+	for name, msg := range raw {
+		capabilities := plans.For(name)
+		err = json.Unmarshal(msg, &capabilities)
+		if err != nil {
+			return nil, fmt.Errorf("%s: plan %q: %w", path, name, err)
+		}
+		err = capabilities.validate()
+		if err != nil {
+			return nil, fmt.Errorf("%s: plan %q: %w", path, name, err)
+		}
+		plans[name] = capabilities
+	}
+	return plans, nil
+}
+
+func (c Capabilities) validate() error {
+	if c.MaxChirpLength < 1 {
+		return fmt.Errorf("max_chirp_length must be at least 1")
+	}
+	if c.RateLimitMultiplier <= 0 {
+		return fmt.Errorf("rate_limit_multiplier must be positive")
+	}
+	if c.MaxPinnedChirps < 0 {
+		return fmt.Errorf("max_pinned_chirps can't be negative")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package events is a publish/subscribe bus for things that happen in
+// Chirpy, such as a chirp being created. With the Postgres bus, events
+// published on one replica reach the subscribers on every replica.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+var ErrPayloadTooLarge = errors.New("event payload is too large")
+
+// Handler is called with the JSON payload of each event on a topic.
+// Handlers are called one at a time, in the order events arrive, so they
+// should hand off anything slow.
+type Handler func(payload []byte)
+
+type Bus interface {
+	// Publish sends payload, encoded as JSON, to every subscriber of topic.
+	Publish(ctx context.Context, topic string, payload any) error
+	// Subscribe registers handler for events on topic. Subscriptions last
+	// as long as the bus.
+	Subscribe(topic string, handler Handler)
+	Close() error
+}
+
+// envelope is how an event travels between replicas.
+type envelope struct {
+	Topic   string          `json:"topic"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// registry holds the subscribers for the implementations.
+type registry struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func (r *registry) Subscribe(topic string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.handlers == nil {
+		r.handlers = map[string][]Handler{}
+	}
+	r.handlers[topic] = append(r.handlers[topic], handler)
+}
+
+// dispatch calls topic's handlers without holding the lock, so a handler
+// can publish or subscribe itself.
+func (r *registry) dispatch(topic string, payload []byte) {
+	r.mu.RLock()
+	topicHandlers := r.handlers[topic]
+	r.mu.RUnlock()
+	for _, handler := range topicHandlers {
+		handler(payload)
+	}
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMemory(t *testing.T) {
+	bus := NewMemory()
+	var got []string
+	bus.Subscribe("a", func(payload []byte) { got = append(got, "first "+string(payload)) })
+	bus.Subscribe("a", func(payload []byte) { got = append(got, "second "+string(payload)) })
+	bus.Subscribe("b", func(payload []byte) { got = append(got, "b "+string(payload)) })
+
+	if err := bus.Publish(context.Background(), "a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`first {"n":1}`, `second {"n":1}`}
+	if !slices.Equal(got, want) {
+		t.Errorf("handlers got %q, want %q", got, want)
+	}
+
+	got = nil
+	if err := bus.Publish(context.Background(), "none", "ignored"); err != nil {
+		t.Errorf("publishing without subscribers: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("handlers of other topics got %q", got)
+	}
+
+	if err := bus.Publish(context.Background(), "a", func() {}); err == nil {
+		t.Error("publishing a payload that isn't JSON succeeded")
+	}
+}
+
+func TestMemoryHandlerCanPublish(t *testing.T) {
+	bus := NewMemory()
+	var got []string
+	bus.Subscribe("first", func(payload []byte) {
+		got = append(got, "first")
+		bus.Subscribe("second", func(payload []byte) { got = append(got, "second") })
+		bus.Publish(context.Background(), "second", nil)
+	})
+
+	bus.Publish(context.Background(), "first", nil)
+	if want := []string{"first", "second"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestNotification(t *testing.T) {
+	empty, err := json.Marshal(envelope{Topic: "chirp.created", Payload: json.RawMessage(`""`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every byte of a plain string payload adds one byte to the envelope.
+	largest := strings.Repeat("x", maxNotifyPayload-len(empty))
+
+	tests := []struct {
+		name    string
+		payload any
+		want    error
+	}{
+		{name: "small", payload: map[string]string{"id": "1"}},
+		{name: "largest that fits", payload: largest},
+		{name: "one byte too many", payload: largest + "x", want: ErrPayloadTooLarge},
+		{name: "far too large", payload: strings.Repeat("x", 10*maxNotifyPayload), want: ErrPayloadTooLarge},
+	}
+	for _, tt := range tests {
+		msg, err := notification("chirp.created", tt.payload)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var got envelope
+		if err := json.Unmarshal([]byte(msg), &got); err != nil {
+			t.Errorf("%s: notification doesn't decode: %v", tt.name, err)
+			continue
+		}
+		want, _ := json.Marshal(tt.payload)
+		if got.Topic != "chirp.created" || string(got.Payload) != string(want) {
+			t.Errorf("%s: got topic %q payload %s", tt.name, got.Topic, got.Payload)
+		}
+	}
+}
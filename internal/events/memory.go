@@ -0,0 +1,29 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Memory is a bus for a single instance. Publish calls the handlers before
+// it returns.
+type Memory struct {
+	registry
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, payload any) error {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	m.dispatch(topic, dat)
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,118 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresChannel is the LISTEN/NOTIFY channel events are sent on.
+const PostgresChannel = "chirpy_events"
+
+// maxNotifyPayload is the largest payload Postgres accepts for NOTIFY by
+// default, less one byte for the terminator.
+const maxNotifyPayload = 7999
+
+const (
+	listenerMinReconnect = 1 * time.Second
+	listenerMaxReconnect = 1 * time.Minute
+	// listenerPingInterval is how long the listener waits without a
+	// notification before checking the connection is still alive.
+	listenerPingInterval = 90 * time.Second
+)
+
+// Postgres is a bus shared by every instance connected to the same
+// database. Events are sent with NOTIFY and received over a dedicated
+// LISTEN connection, so the publishing instance gets its own events back
+// the same way as everyone else. Events published while the listener is
+// reconnecting are lost.
+type Postgres struct {
+	registry
+	db       *sql.DB
+	listener *pq.Listener
+	done     chan struct{}
+}
+
+// NewPostgres publishes through db and listens on a new connection to
+// dbURL.
+func NewPostgres(db *sql.DB, dbURL string) (*Postgres, error) {
+	listener := pq.NewListener(dbURL, listenerMinReconnect, listenerMaxReconnect, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			fmt.Println(fmt.Errorf("event bus listener: %w", err))
+		}
+	})
+	if err := listener.Listen(PostgresChannel); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	p := &Postgres{
+		db:       db,
+		listener: listener,
+		done:     make(chan struct{}),
+	}
+	go p.listen()
+	return p, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, topic string, payload any) error {
+	msg, err := notification(topic, payload)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", PostgresChannel, msg)
+	return err
+}
+
+// notification encodes an event for NOTIFY, or returns ErrPayloadTooLarge
+// if it won't fit.
+func notification(topic string, payload any) (string, error) {
+	dat, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	msg, err := json.Marshal(envelope{Topic: topic, Payload: dat})
+	if err != nil {
+		return "", err
+	}
+	if len(msg) > maxNotifyPayload {
+		return "", ErrPayloadTooLarge
+	}
+	return string(msg), nil
+}
+
+func (p *Postgres) Close() error {
+	close(p.done)
+	return p.listener.Close()
+}
+
+func (p *Postgres) listen() {
+	ticker := time.NewTicker(listenerPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case notification, ok := <-p.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification means the connection was re-established.
+			if notification == nil {
+				continue
+			}
+			var msg envelope
+			if err := json.Unmarshal([]byte(notification.Extra), &msg); err != nil {
+				fmt.Println(fmt.Errorf("event bus: %w", err))
+				continue
+			}
+			p.dispatch(msg.Topic, msg.Payload)
+		case <-ticker.C:
+			go p.listener.Ping()
+		}
+	}
+}
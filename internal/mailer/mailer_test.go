@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestFileSenderRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  Message
+		want error
+	}{
+		{name: "plain", msg: Message{To: "a@example.com", Subject: "Log in", Body: "line one\nline two"}},
+		{name: "LF in recipient", msg: Message{To: "a@example.com\nBcc: b@example.com", Subject: "Log in"}, want: ErrHeaderInjection},
+		{name: "CR in recipient", msg: Message{To: "a@example.com\rBcc: b@example.com", Subject: "Log in"}, want: ErrHeaderInjection},
+		{name: "CRLF in subject", msg: Message{To: "a@example.com", Subject: "Log in\r\nBcc: b@example.com"}, want: ErrHeaderInjection},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		err := FileSender{Dir: dir, From: "chirpy@example.com"}.Send(context.Background(), tt.msg)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+		files, _ := os.ReadDir(dir)
+		if wrote := len(files) > 0; wrote != (tt.want == nil) {
+			t.Errorf("%s: wrote %d files", tt.name, len(files))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package moderation decides what happens to a chirp before it is stored.
+// Moderators can be chained, so the built-in banned-word list and external
+// classifiers called over HTTP run one after another on every chirp.
+package moderation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+type Decision string
+
+const (
+	// DecisionAllow stores the chirp unchanged.
+	DecisionAllow Decision = "allow"
+	// DecisionMask stores the chirp with the body returned by the moderator.
+	DecisionMask Decision = "mask"
+	// DecisionFlag stores and shows the chirp but marks it for review.
+	DecisionFlag Decision = "flag"
+	// DecisionHold stores the chirp but hides it until a moderator approves
+	// it.
+	DecisionHold Decision = "hold"
+	// DecisionReject refuses the chirp.
+	DecisionReject Decision = "reject"
+)
+
+func ParseDecision(s string) (Decision, error) {
+	switch Decision(s) {
+	case DecisionAllow, DecisionMask, DecisionFlag, DecisionHold, DecisionReject:
+		return Decision(s), nil
+	}
+	return "", fmt.Errorf("unknown moderation decision %q", s)
+}
+
+// Content is a chirp about to be created or edited.
+type Content struct {
+	UserID         uuid.UUID
+	Body           string
+	ContentWarning string
+	Sensitive      bool
+}
+
+type Result struct {
+	Decision Decision
+	// Body is the text to store. It differs from the submitted body when a
+	// moderator masked part of it.
+	Body string
+	// Reason explains a reject, hold or flag decision.
+	Reason string
+}
+
+type Moderator interface {
+	Moderate(ctx context.Context, content Content) (Result, error)
+}
+
+// Chain runs moderators in order. Each sees the body as left by the previous
+// one, the strictest decision wins, and the chain stops at the first reject.
+type Chain []Moderator
+
+func (c Chain) Moderate(ctx context.Context, content Content) (Result, error) {
+	res := Result{Decision: DecisionAllow, Body: content.Body}
+	for _, m := range c {
+		next, err := m.Moderate(ctx, content)
+		if err != nil {
+			return Result{}, err
+		}
+		content.Body = next.Body
+		res.Body = next.Body
+		if severity(next.Decision) > severity(res.Decision) {
+			res.Decision = next.Decision
+			res.Reason = next.Reason
+		}
+		if res.Decision == DecisionReject {
+			break
+		}
+	}
+	return res, nil
+}
+
+func severity(decision Decision) int {
+	switch decision {
+	case DecisionReject:
+		return 4
+	case DecisionHold:
+		return 3
+	case DecisionFlag:
+		return 2
+	case DecisionMask:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUnavailable is returned by a fail-closed Webhook when the service could
+// not give a decision.
+var ErrUnavailable = errors.New("moderation service unavailable")
+
+const defaultRejectReason = "Chirp was rejected by moderation"
+
+// Webhook asks an external service for a decision. It POSTs
+//
+//	{"user_id": "...", "body": "...", "content_warning": "...", "sensitive": false}
+//
+// and expects
+//
+//	{"decision": "allow", "body": "...", "reason": "..."}
+//
+// where body is only required for "mask".
+type Webhook struct {
+	URL     string
+	Timeout time.Duration
+	// FailOpen allows chirps through when the service errors, times out or
+	// answers with something unusable. Otherwise such chirps are refused with
+	// ErrUnavailable.
+	FailOpen bool
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+func (wh Webhook) Moderate(ctx context.Context, content Content) (Result, error) {
+	res, err := wh.call(ctx, content)
+	if err != nil {
+		if wh.FailOpen {
+			return Result{Decision: DecisionAllow, Body: content.Body}, nil
+		}
+		return Result{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return res, nil
+}
+
+func (wh Webhook) call(ctx context.Context, content Content) (Result, error) {
+	type request struct {
+		UserID         uuid.UUID `json:"user_id"`
+		Body           string    `json:"body"`
+		ContentWarning string    `json:"content_warning"`
+		Sensitive      bool      `json:"sensitive"`
+	}
+	type response struct {
+		Decision string  `json:"decision"`
+		Body     *string `json:"body"`
+		Reason   string  `json:"reason"`
+	}
+
+	if wh.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wh.Timeout)
+		defer cancel()
+	}
+	dat, err := json.Marshal(request{
+		UserID:         content.UserID,
+		Body:           content.Body,
+		ContentWarning: content.ContentWarning,
+		Sensitive:      content.Sensitive,
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(dat))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := wh.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("moderation webhook returned %s", resp.Status)
+	}
+
+	decoded := response{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decoded); err != nil {
+		return Result{}, err
+	}
+	decision, err := ParseDecision(decoded.Decision)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Decision: decision, Body: content.Body, Reason: decoded.Reason}
+	// The reason is shown to the author of a rejected chirp, so it can't be
+	// left empty.
+	if decision == DecisionReject && res.Reason == "" {
+		res.Reason = defaultRejectReason
+	}
+	if decision == DecisionMask {
+		if decoded.Body == nil {
+			return Result{}, errors.New("moderation webhook masked without returning a body")
+		}
+		res.Body = *decoded.Body
+	}
+	return res, nil
+}
@@ -0,0 +1,170 @@
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWebhook(t *testing.T) {
+	content := Content{
+		UserID: uuid.New(),
+		Body:   "this is a kerfuffle",
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    Result
+	}{
+		{
+			name:    "allow",
+			handler: respondJSON(`{"decision": "allow"}`),
+			want:    Result{Decision: DecisionAllow, Body: content.Body},
+		},
+		{
+			name:    "mask",
+			handler: respondJSON(`{"decision": "mask", "body": "this is a ****"}`),
+			want:    Result{Decision: DecisionMask, Body: "this is a ****"},
+		},
+		{
+			name:    "reject",
+			handler: respondJSON(`{"decision": "reject", "reason": "Too spicy"}`),
+			want:    Result{Decision: DecisionReject, Body: content.Body, Reason: "Too spicy"},
+		},
+		{
+			name:    "reject without a reason",
+			handler: respondJSON(`{"decision": "reject"}`),
+			want:    Result{Decision: DecisionReject, Body: content.Body, Reason: defaultRejectReason},
+		},
+		{
+			name:    "hold",
+			handler: respondJSON(`{"decision": "hold", "reason": "Needs a look"}`),
+			want:    Result{Decision: DecisionHold, Body: content.Body, Reason: "Needs a look"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			got, err := Webhook{URL: srv.URL}.Moderate(context.Background(), content)
+			if err != nil {
+				t.Fatalf("Moderate() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Moderate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookSendsContent(t *testing.T) {
+	content := Content{
+		UserID:         uuid.New(),
+		Body:           "hello",
+		ContentWarning: "spoilers",
+		Sensitive:      true,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var got struct {
+			UserID         uuid.UUID `json:"user_id"`
+			Body           string    `json:"body"`
+			ContentWarning string    `json:"content_warning"`
+			Sensitive      bool      `json:"sensitive"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if got.UserID != content.UserID || got.Body != content.Body || got.ContentWarning != content.ContentWarning || !got.Sensitive {
+			t.Errorf("webhook received %+v, want %+v", got, content)
+		}
+		w.Write([]byte(`{"decision": "allow"}`))
+	}))
+	defer srv.Close()
+
+	if _, err := (Webhook{URL: srv.URL}).Moderate(context.Background(), content); err != nil {
+		t.Fatalf("Moderate() error = %v", err)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	content := Content{UserID: uuid.New(), Body: "hello"}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		timeout time.Duration
+	}{
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				select {
+				case <-req.Context().Done():
+				case <-time.After(200 * time.Millisecond):
+				}
+				w.Write([]byte(`{"decision": "allow"}`))
+			},
+			timeout: 20 * time.Millisecond,
+		},
+		{
+			name: "non-2xx",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		},
+		{
+			name:    "unknown decision",
+			handler: respondJSON(`{"decision": "maybe"}`),
+		},
+		{
+			name:    "mask without a body",
+			handler: respondJSON(`{"decision": "mask"}`),
+		},
+		{
+			name:    "not JSON",
+			handler: respondJSON(`allow`),
+		},
+	}
+	for _, tt := range tests {
+		for _, failOpen := range []bool{true, false} {
+			name := tt.name + "/fail closed"
+			if failOpen {
+				name = tt.name + "/fail open"
+			}
+			t.Run(name, func(t *testing.T) {
+				srv := httptest.NewServer(tt.handler)
+				defer srv.Close()
+
+				wh := Webhook{URL: srv.URL, Timeout: tt.timeout, FailOpen: failOpen}
+				got, err := wh.Moderate(context.Background(), content)
+				if failOpen {
+					if err != nil {
+						t.Fatalf("Moderate() error = %v, want the chirp allowed", err)
+					}
+					want := Result{Decision: DecisionAllow, Body: content.Body}
+					if got != want {
+						t.Errorf("Moderate() = %+v, want %+v", got, want)
+					}
+					return
+				}
+				if !errors.Is(err, ErrUnavailable) {
+					t.Errorf("Moderate() error = %v, want ErrUnavailable", err)
+				}
+			})
+		}
+	}
+}
+
+func respondJSON(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
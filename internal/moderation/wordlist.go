@@ -0,0 +1,29 @@
+package moderation
+
+import (
+	"context"
+
+	"github.com/ifeanyibatman/chirpy/internal/profanity"
+)
+
+// WordList moderates with the banned-word filter. It reads the filter from
+// the holder on every call, so changes to the word list apply immediately.
+type WordList struct {
+	Filter *profanity.Holder
+}
+
+func (wl WordList) Moderate(ctx context.Context, content Content) (Result, error) {
+	checked := wl.Filter.Load().Check(content.Body)
+	res := Result{Decision: DecisionAllow, Body: checked.Text}
+	switch {
+	case checked.Rejected:
+		res.Decision = DecisionReject
+		res.Reason = "Chirp contains prohibited language"
+	case checked.Flagged:
+		res.Decision = DecisionFlag
+		res.Reason = "Chirp contains flagged language"
+	case len(checked.Matches) > 0:
+		res.Decision = DecisionMask
+	}
+	return res, nil
+}
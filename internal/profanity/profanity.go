@@ -0,0 +1,273 @@
+// Package profanity finds banned words in chirps. Words are compared after
+// Unicode normalisation, case folding and undoing common leetspeak, so
+// "Kerfuffle!", "KERFUFFLE" and "k3rfuffl3" all match "kerfuffle", while the
+// text around a match, including its whitespace, is left exactly as written.
+package profanity
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the word with asterisks.
+	ActionMask Action = "mask"
+	// ActionReject refuses the whole chirp.
+	ActionReject Action = "reject"
+	// ActionFlag keeps the chirp as written but marks it for review.
+	ActionFlag Action = "flag"
+)
+
+const mask = "****"
+
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case ActionMask, ActionReject, ActionFlag:
+		return Action(s), nil
+	}
+	return "", fmt.Errorf("unknown profanity action %q", s)
+}
+
+type Entry struct {
+	Word   string
+	Action Action
+}
+
+type Match struct {
+	// Word is the matched text as it appeared in the input.
+	Word string
+	// Entry is the normalised banned word it matched.
+	Entry  string
+	Action Action
+}
+
+type Result struct {
+	Text     string
+	Matches  []Match
+	Rejected bool
+	Flagged  bool
+}
+
+// DefaultEntries returns the words migration 011 seeds banned_words with,
+// for when the table can't be read.
+func DefaultEntries() []Entry {
+	return []Entry{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionMask},
+	}
+}
+
+type Filter struct {
+	words map[string]Action
+}
+
+// New builds a filter from entries. When a word is listed more than once the
+// strictest action wins.
+func New(entries []Entry) *Filter {
+	f := &Filter{words: map[string]Action{}}
+	for _, entry := range entries {
+		word := Normalize(entry.Word)
+		if word == "" {
+			continue
+		}
+		if current, ok := f.words[word]; !ok || severity(entry.Action) > severity(current) {
+			f.words[word] = entry.Action
+		}
+	}
+	return f
+}
+
+func (f *Filter) Len() int {
+	return len(f.words)
+}
+
+// Check scans text and applies the action of every banned word found.
+func (f *Filter) Check(text string) Result {
+	res := Result{}
+	out := &strings.Builder{}
+	rs := []rune(text)
+
+	for i := 0; i < len(rs); {
+		if !isWordRune(rs, i) {
+			out.WriteRune(rs[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(rs) && isWordRune(rs, j) {
+			j++
+		}
+		out.WriteString(f.checkToken(string(rs[i:j]), &res))
+		i = j
+	}
+
+	res.Text = out.String()
+	return res
+}
+
+// checkToken matches one token, first as a whole and then with leetspeak
+// symbols trimmed from its ends, so "$fornax" still matches while the "$"
+// stays in the output.
+func (f *Filter) checkToken(token string, res *Result) string {
+	candidates := []string{token}
+	if trimmed := strings.Trim(token, leetSymbols); trimmed != token && trimmed != "" {
+		candidates = append(candidates, trimmed)
+	}
+	for _, candidate := range candidates {
+		normalized := Normalize(candidate)
+		action, ok := f.words[normalized]
+		if !ok {
+			continue
+		}
+		res.Matches = append(res.Matches, Match{Word: candidate, Entry: normalized, Action: action})
+		switch action {
+		case ActionReject:
+			res.Rejected = true
+		case ActionFlag:
+			res.Flagged = true
+			return token
+		}
+		return strings.Replace(token, candidate, mask, 1)
+	}
+	return token
+}
+
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+}
+
+// leetSymbols can start or end a word; innerLeetSymbols only count as part of
+// a word when letters surround them, so "wow!" keeps its punctuation.
+const (
+	leetSymbols      = "@$"
+	innerLeetSymbols = "!|"
+)
+
+func isWordRune(rs []rune, i int) bool {
+	r := rs[i]
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || strings.ContainsRune(leetSymbols, r) {
+		return true
+	}
+	if strings.ContainsRune(innerLeetSymbols, r) && i > 0 && i < len(rs)-1 {
+		return isLetterOrDigit(rs[i-1]) && isLetterOrDigit(rs[i+1])
+	}
+	return false
+}
+
+func isLetterOrDigit(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var folder = cases.Fold()
+
+// Normalize reduces a word to the form banned words are compared in:
+// compatibility-decomposed with accents removed, case folded and with
+// leetspeak substitutions undone.
+func Normalize(word string) string {
+	t := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	decomposed, _, err := transform.String(t, word)
+	if err != nil {
+		decomposed = word
+	}
+	folded := folder.String(decomposed)
+	return strings.Map(func(r rune) rune {
+		if replacement, ok := leet[r]; ok {
+			return replacement
+		}
+		if !isLetterOrDigit(r) {
+			return -1
+		}
+		return r
+	}, folded)
+}
+
+// ParseList reads one word per line, optionally followed by an action
+// ("mask", "reject" or "flag"; "mask" when omitted). Blank lines and lines
+// starting with # are ignored.
+func ParseList(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		entry := Entry{Word: fields[0], Action: ActionMask}
+		if len(fields) > 1 {
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			entry.Action = action
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+func LoadFile(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseList(file)
+}
+
+func severity(action Action) int {
+	switch action {
+	case ActionReject:
+		return 3
+	case ActionFlag:
+		return 2
+	case ActionMask:
+		return 1
+	}
+	return 0
+}
+
+// Holder shares the current filter between request handlers and lets it be
+// swapped out at runtime without locking readers.
+type Holder struct {
+	current atomic.Pointer[Filter]
+}
+
+func NewHolder(f *Filter) *Holder {
+	h := &Holder{}
+	h.Store(f)
+	return h
+}
+
+func (h *Holder) Load() *Filter {
+	return h.current.Load()
+}
+
+func (h *Holder) Store(f *Filter) {
+	h.current.Store(f)
+}
@@ -0,0 +1,120 @@
+package profanity
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFilter() *Filter {
+	return New([]Entry{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "fornax", Action: ActionMask},
+		{Word: "darn", Action: ActionFlag},
+		{Word: "blarg", Action: ActionReject},
+	})
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		want     string
+		rejected bool
+		flagged  bool
+		matches  int
+	}{
+		{name: "clean", text: "This is a clean chirp", want: "This is a clean chirp"},
+		{name: "mask", text: "This is a kerfuffle opinion", want: "This is a **** opinion", matches: 1},
+		{name: "upper case", text: "KERFUFFLE!", want: "****!", matches: 1},
+		{name: "several", text: "kerfuffle and fornax", want: "**** and ****", matches: 2},
+		{name: "whitespace kept", text: "  kerfuffle\tsharp \n", want: "  ****\tsharp \n", matches: 1},
+		{name: "whole words only", text: "kerfuffles are fine", want: "kerfuffles are fine"},
+		{name: "leetspeak digits", text: "what a k3rfuffl3", want: "what a ****", matches: 1},
+		{name: "leetspeak symbols", text: "f0rn@x", want: "****", matches: 1},
+		{name: "leading symbol kept", text: "$fornax", want: "$****", matches: 1},
+		{name: "punctuation kept", text: "wow! fornax!", want: "wow! ****!", matches: 1},
+		{name: "accents", text: "Kérfüffle", want: "****", matches: 1},
+		{name: "fullwidth", text: "ｆｏｒｎａｘ", want: "****", matches: 1},
+		{name: "flag", text: "well darn", want: "well darn", flagged: true, matches: 1},
+		{name: "reject", text: "blarg it", want: "**** it", rejected: true, matches: 1},
+	}
+	filter := testFilter()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filter.Check(tt.text)
+			if got.Text != tt.want {
+				t.Errorf("Check(%q).Text = %q, want %q", tt.text, got.Text, tt.want)
+			}
+			if got.Rejected != tt.rejected {
+				t.Errorf("Check(%q).Rejected = %v, want %v", tt.text, got.Rejected, tt.rejected)
+			}
+			if got.Flagged != tt.flagged {
+				t.Errorf("Check(%q).Flagged = %v, want %v", tt.text, got.Flagged, tt.flagged)
+			}
+			if len(got.Matches) != tt.matches {
+				t.Errorf("Check(%q) found %d matches, want %d", tt.text, len(got.Matches), tt.matches)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{word: "Kerfuffle", want: "kerfuffle"},
+		{word: "K3rfuffl3", want: "kerfuffle"},
+		{word: "$h@rb3rt", want: "sharbert"},
+		{word: "naïve", want: "naive"},
+		{word: "Ｆｏｒｎａｘ", want: "fornax"},
+		{word: "STRASSE", want: "strasse"},
+		{word: "h-e-l-l-o", want: "hello"},
+		{word: "!!!", want: "iii"},
+		{word: "...", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.word); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestNewKeepsStrictestAction(t *testing.T) {
+	filter := New([]Entry{
+		{Word: "fornax", Action: ActionMask},
+		{Word: "F0RNAX", Action: ActionReject},
+		{Word: "fornax", Action: ActionFlag},
+		{Word: "???", Action: ActionReject},
+	})
+	if filter.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", filter.Len())
+	}
+	if got := filter.Check("fornax"); !got.Rejected {
+		t.Errorf("Check(fornax) = %+v, want it rejected", got)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	entries, err := ParseList(strings.NewReader("# banned words\nkerfuffle\n\nfornax reject\ndarn flag\n"))
+	if err != nil {
+		t.Fatalf("ParseList() error = %v", err)
+	}
+	want := []Entry{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "fornax", Action: ActionReject},
+		{Word: "darn", Action: ActionFlag},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ParseList() = %+v, want %+v", entries, want)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	if _, err := ParseList(strings.NewReader("fornax delete\n")); err == nil {
+		t.Error("ParseList() accepted an unknown action")
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies lists the reverse proxies whose X-Forwarded-For header is
+// believed. Without any, the client IP is always the connection's remote
+// address, since anyone can send an X-Forwarded-For header.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies reads a comma separated list of IP addresses and CIDR
+// ranges, such as "10.0.0.0/8, 127.0.0.1".
+func ParseTrustedProxies(s string) (TrustedProxies, error) {
+	proxies := TrustedProxies{}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", field, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", field, err)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return proxies, nil
+}
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range t {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client that made req. When the request
+// came through a trusted proxy, X-Forwarded-For is walked from the right,
+// skipping further trusted proxies, and the first untrusted address is the
+// client.
+func (t TrustedProxies) ClientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	client := remote.Unmap()
+	if !t.contains(client) {
+		return client.String()
+	}
+
+	hops := []string{}
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// A malformed entry can't be trusted; stop at the last good hop.
+			break
+		}
+		client = addr.Unmap()
+		if !t.contains(client) {
+			break
+		}
+	}
+	return client.String()
+}
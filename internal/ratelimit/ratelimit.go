@@ -0,0 +1,147 @@
+// Package ratelimit implements token-bucket rate limiting keyed by an
+// arbitrary identity string, plus the helpers needed to put it in front of
+// HTTP handlers: the RateLimit-* and Retry-After response headers and client
+// IP resolution behind trusted reverse proxies.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests requests per Per. Buckets start full, so a client can
+// burst up to Requests requests at once and then continues at the average
+// rate.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads limits written as "<requests>/<duration>", for example
+// "30/1m" or "5/10s".
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q: expected <requests>/<duration>", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid number of requests", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(per))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q: invalid duration", s)
+	}
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Scale returns the limit with its number of requests multiplied by factor.
+func (l Limit) Scale(factor float64) Limit {
+	return Limit{Requests: max(1, int(float64(l.Requests)*factor)), Per: l.Per}
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Decision is the outcome of a single Allow call.
+type Decision struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed. It is
+	// zero when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// WriteHeaders sets the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers, plus Retry-After when the
+// request was refused.
+func (d Decision) WriteHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", d.Limit.Requests, seconds(d.Limit.Per)))
+	if !d.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(1, seconds(d.RetryAfter))))
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Limiter holds one token bucket per key. It is safe for concurrent use.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for key, creating it if needed. The
+// limit may change between calls for the same key, for example when a user
+// upgrades to a tier with higher limits; the bucket keeps its tokens and
+// refills at the new rate from then on.
+func (l *Limiter) Allow(key string, limit Limit) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now, limit: limit}
+		l.buckets[key] = b
+	}
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Requests), b.tokens+elapsed*b.limit.rate())
+	b.updated = now
+	b.limit = limit
+
+	d := Decision{Limit: limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = time.Duration((1 - b.tokens) / limit.rate() * float64(time.Second))
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = time.Duration((float64(limit.Requests) - b.tokens) / limit.rate() * float64(time.Second))
+	return d
+}
+
+// sweep drops buckets that have been idle long enough to be full again, since
+// a fresh bucket would behave identically. It runs at most once a minute.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.updated) > b.limit.Per {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAllowRefill(t *testing.T) {
+	limit := Limit{Requests: 3, Per: 3 * time.Second}
+
+	// Each step advances the clock by wait and then makes one request.
+	steps := []struct {
+		name       string
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{name: "burst 1", allowed: true, remaining: 2},
+		{name: "burst 2", allowed: true, remaining: 1},
+		{name: "burst 3", allowed: true, remaining: 0},
+		{name: "empty", allowed: false, remaining: 0, retryAfter: time.Second},
+		{name: "half a token", wait: 500 * time.Millisecond, allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+		{name: "one token", wait: 500 * time.Millisecond, allowed: true, remaining: 0},
+		{name: "refilled to the limit", wait: time.Minute, allowed: true, remaining: 2},
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+	for _, step := range steps {
+		now = now.Add(step.wait)
+		got := l.Allow("user", limit)
+		if got.Allowed != step.allowed || got.Remaining != step.remaining || got.RetryAfter != step.retryAfter {
+			t.Errorf("%s: Allow() = %+v, want allowed %v, remaining %d, retry after %s",
+				step.name, got, step.allowed, step.remaining, step.retryAfter)
+		}
+	}
+}
+
+func TestAllowKeepsKeysApart(t *testing.T) {
+	l := New()
+	limit := Limit{Requests: 1, Per: time.Minute}
+	if !l.Allow("a", limit).Allowed {
+		t.Fatal("first request for a was refused")
+	}
+	if l.Allow("a", limit).Allowed {
+		t.Error("second request for a was allowed")
+	}
+	if !l.Allow("b", limit).Allowed {
+		t.Error("first request for b was refused")
+	}
+}
+
+func TestAllowRaisedLimit(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+
+	low := Limit{Requests: 1, Per: time.Minute}
+	high := low.Scale(4)
+	l.Allow("user", low)
+
+	// Time before the change refills at the old rate: a quarter of a token.
+	now = now.Add(15 * time.Second)
+	if got := l.Allow("user", high); got.Allowed {
+		t.Errorf("Allow() straight after raising the limit = %+v, want refused", got)
+	}
+	// From then on the bucket refills at four tokens a minute.
+	now = now.Add(15 * time.Second)
+	if got := l.Allow("user", high); !got.Allowed {
+		t.Errorf("Allow() 15s after raising the limit = %+v, want allowed", got)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{s: "30/1m", want: Limit{Requests: 30, Per: time.Minute}},
+		{s: " 5 / 10s ", want: Limit{Requests: 5, Per: 10 * time.Second}},
+		{s: "30", wantErr: true},
+		{s: "0/1m", wantErr: true},
+		{s: "x/1m", wantErr: true},
+		{s: "30/forever", wantErr: true},
+		{s: "30/-1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseLimit(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	h := http.Header{}
+	Decision{
+		Allowed:    false,
+		Limit:      Limit{Requests: 30, Per: time.Minute},
+		Remaining:  0,
+		Reset:      1500 * time.Millisecond,
+		RetryAfter: 200 * time.Millisecond,
+	}.WriteHeaders(h)
+
+	want := map[string]string{
+		"RateLimit-Limit":     "30",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "2",
+		"RateLimit-Policy":    "30;w=60",
+		"Retry-After":         "1",
+	}
+	for name, value := range want {
+		if got := h.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 127.0.0.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:5000", want: "203.0.113.7"},
+		{name: "untrusted forwarder", remoteAddr: "203.0.113.7:5000", forwardedFor: "198.51.100.1", want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwardedFor: "198.51.100.1", want: "198.51.100.1"},
+		{name: "proxy chain", remoteAddr: "127.0.0.1:5000", forwardedFor: "198.51.100.1, 10.0.0.2", want: "198.51.100.1"},
+		{name: "spoofed hop", remoteAddr: "10.1.2.3:5000", forwardedFor: "1.1.1.1, 198.51.100.1", want: "198.51.100.1"},
+		{name: "malformed hop", remoteAddr: "10.1.2.3:5000", forwardedFor: "198.51.100.1, nonsense", want: "10.1.2.3"},
+		{name: "IPv4-mapped", remoteAddr: "[::ffff:203.0.113.7]:5000", want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if got := proxies.ClientIP(req); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+// Package spam spots chirps that look automated: near-duplicates of what the
+// same user posted shortly before, bodies that are mostly links, and bursts
+// of posting faster than a person would.
+package spam
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Action string
+
+const (
+	ActionAllow Action = "allow"
+	// ActionHold posts the chirp but keeps it out of public view until a
+	// moderator reviews it.
+	ActionHold Action = "hold"
+	// ActionReject refuses the chirp.
+	ActionReject Action = "reject"
+)
+
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case ActionHold, ActionReject:
+		return Action(s), nil
+	}
+	return "", fmt.Errorf("unknown spam action %q", s)
+}
+
+// Rule names the check that produced a verdict.
+type Rule string
+
+const (
+	RuleDuplicate Rule = "duplicate"
+	RuleLinks     Rule = "links"
+	RuleVelocity  Rule = "velocity"
+)
+
+type Config struct {
+	// DuplicateWindow is how far back to look for near-duplicates.
+	DuplicateWindow time.Duration
+	// DuplicateSimilarity is the similarity, from 0 to 1, at which two chirps
+	// count as duplicates. 1 only matches chirps that are identical after
+	// normalisation.
+	DuplicateSimilarity float64
+	DuplicateAction     Action
+
+	// MaxLinks is the most links a chirp may contain.
+	MaxLinks int
+	// MaxLinkDensity is the largest share of a chirp's characters, from 0 to
+	// 1, that may be taken up by links.
+	MaxLinkDensity float64
+	LinkAction     Action
+
+	// VelocityLimit is how many chirps a user may post within VelocityWindow.
+	VelocityLimit  int
+	VelocityWindow time.Duration
+	VelocityAction Action
+}
+
+func DefaultConfig() Config {
+	return Config{
+		DuplicateWindow:     10 * time.Minute,
+		DuplicateSimilarity: 0.8,
+		DuplicateAction:     ActionReject,
+		MaxLinks:            3,
+		MaxLinkDensity:      0.6,
+		LinkAction:          ActionHold,
+		VelocityLimit:       10,
+		VelocityWindow:      time.Minute,
+		VelocityAction:      ActionReject,
+	}
+}
+
+// Lookback is how far back Check needs to see the user's chirps.
+func (c Config) Lookback() time.Duration {
+	return max(c.DuplicateWindow, c.VelocityWindow)
+}
+
+// Post is an earlier chirp by the same user.
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+type Verdict struct {
+	Action Action
+	Rule   Rule
+	Reason string
+	// RetryAfter is set for velocity verdicts: how long until the user is
+	// back under the limit.
+	RetryAfter time.Duration
+}
+
+// Check judges body, posted at now, against the user's recent posts. The
+// strictest verdict wins; among equally strict ones, velocity is reported
+// before duplicates and duplicates before links.
+func (c Config) Check(body string, recent []Post, now time.Time) Verdict {
+	verdicts := []Verdict{
+		c.checkVelocity(recent, now),
+		c.checkDuplicate(body, recent, now),
+		c.checkLinks(body),
+	}
+	res := Verdict{Action: ActionAllow}
+	for _, v := range verdicts {
+		if severity(v.Action) > severity(res.Action) {
+			res = v
+		}
+	}
+	return res
+}
+
+func (c Config) checkVelocity(recent []Post, now time.Time) Verdict {
+	if c.VelocityLimit <= 0 {
+		return Verdict{Action: ActionAllow}
+	}
+	within := []time.Time{}
+	for _, post := range recent {
+		if now.Sub(post.CreatedAt) < c.VelocityWindow {
+			within = append(within, post.CreatedAt)
+		}
+	}
+	if len(within) < c.VelocityLimit {
+		return Verdict{Action: ActionAllow}
+	}
+	oldest := within[0]
+	for _, t := range within {
+		if t.Before(oldest) {
+			oldest = t
+		}
+	}
+	return Verdict{
+		Action:     c.VelocityAction,
+		Rule:       RuleVelocity,
+		Reason:     fmt.Sprintf("More than %d chirps in %s", c.VelocityLimit, c.VelocityWindow),
+		RetryAfter: c.VelocityWindow - now.Sub(oldest),
+	}
+}
+
+func (c Config) checkDuplicate(body string, recent []Post, now time.Time) Verdict {
+	if c.DuplicateWindow <= 0 {
+		return Verdict{Action: ActionAllow}
+	}
+	normalized := Normalize(body)
+	shingles := shingle(normalized)
+	for _, post := range recent {
+		if now.Sub(post.CreatedAt) >= c.DuplicateWindow {
+			continue
+		}
+		other := Normalize(post.Body)
+		if other == normalized || similarity(shingles, shingle(other)) >= c.DuplicateSimilarity {
+			return Verdict{
+				Action: c.DuplicateAction,
+				Rule:   RuleDuplicate,
+				Reason: "Chirp is too similar to one you posted recently",
+			}
+		}
+	}
+	return Verdict{Action: ActionAllow}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://\S+|\bwww\.\S+`)
+
+func (c Config) checkLinks(body string) Verdict {
+	links := linkPattern.FindAllString(body, -1)
+	if len(links) == 0 {
+		return Verdict{Action: ActionAllow}
+	}
+	if c.MaxLinks > 0 && len(links) > c.MaxLinks {
+		return Verdict{
+			Action: c.LinkAction,
+			Rule:   RuleLinks,
+			Reason: fmt.Sprintf("Chirp contains more than %d links", c.MaxLinks),
+		}
+	}
+	linkChars := 0
+	for _, link := range links {
+		linkChars += len(link)
+	}
+	total := len(strings.TrimSpace(body))
+	if c.MaxLinkDensity > 0 && float64(linkChars)/float64(total) > c.MaxLinkDensity {
+		return Verdict{
+			Action: c.LinkAction,
+			Rule:   RuleLinks,
+			Reason: "Chirp is mostly links",
+		}
+	}
+	return Verdict{Action: ActionAllow}
+}
+
+// Normalize lowercases text, drops punctuation and collapses whitespace, so
+// that trivial edits like added exclamation marks or spacing don't make a
+// repeated chirp look new.
+func Normalize(text string) string {
+	b := &strings.Builder{}
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		case unicode.IsSpace(r):
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// shingle splits text into overlapping character trigrams, which tolerate
+// small insertions and typos better than comparing whole words.
+func shingle(text string) map[string]struct{} {
+	rs := []rune(text)
+	res := map[string]struct{}{}
+	if len(rs) < 3 {
+		res[text] = struct{}{}
+		return res
+	}
+	for i := 0; i+3 <= len(rs); i++ {
+		res[string(rs[i:i+3])] = struct{}{}
+	}
+	return res
+}
+
+// similarity is the Jaccard index of two shingle sets.
+func similarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for s := range a {
+		if _, ok := b[s]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func severity(action Action) int {
+	switch action {
+	case ActionReject:
+		return 2
+	case ActionHold:
+		return 1
+	}
+	return 0
+}
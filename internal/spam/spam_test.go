@@ -0,0 +1,162 @@
+package spam
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ago := func(d time.Duration) time.Time { return now.Add(-d) }
+	burst := func(n int, within time.Duration) []Post {
+		posts := []Post{}
+		for i := range n {
+			posts = append(posts, Post{Body: strings.Repeat("x", i+1), CreatedAt: ago(within * time.Duration(n-i) / time.Duration(n+1))})
+		}
+		return posts
+	}
+
+	tests := []struct {
+		name   string
+		config func(*Config)
+		body   string
+		recent []Post
+		action Action
+		rule   Rule
+	}{
+		{
+			name:   "nothing recent",
+			body:   "Hello Chirpy",
+			action: ActionAllow,
+		},
+		{
+			name:   "exact duplicate",
+			body:   "Buy my course today",
+			recent: []Post{{Body: "Buy my course today", CreatedAt: ago(time.Minute)}},
+			action: ActionReject,
+			rule:   RuleDuplicate,
+		},
+		{
+			name:   "duplicate after normalisation",
+			body:   "BUY my course   today!!!",
+			recent: []Post{{Body: "buy my course today", CreatedAt: ago(time.Minute)}},
+			action: ActionReject,
+			rule:   RuleDuplicate,
+		},
+		{
+			name:   "near duplicate",
+			body:   "Buy my amazing course today, everyone",
+			recent: []Post{{Body: "Buy my amazing course today, everyone!", CreatedAt: ago(time.Minute)}},
+			action: ActionReject,
+			rule:   RuleDuplicate,
+		},
+		{
+			name:   "different chirp",
+			body:   "What a lovely morning",
+			recent: []Post{{Body: "Buy my course today", CreatedAt: ago(time.Minute)}},
+			action: ActionAllow,
+		},
+		{
+			name:   "duplicate outside the window",
+			body:   "Buy my course today",
+			recent: []Post{{Body: "Buy my course today", CreatedAt: ago(11 * time.Minute)}},
+			action: ActionAllow,
+		},
+		{
+			name:   "duplicate check disabled",
+			config: func(c *Config) { c.DuplicateWindow = 0 },
+			body:   "Buy my course today",
+			recent: []Post{{Body: "Buy my course today", CreatedAt: ago(time.Minute)}},
+			action: ActionAllow,
+		},
+		{
+			name:   "one link",
+			body:   "Read about it at https://example.com/post before the weekend",
+			action: ActionAllow,
+		},
+		{
+			name:   "too many links",
+			body:   "a https://a.example b https://b.example c https://c.example d www.d.example",
+			action: ActionHold,
+			rule:   RuleLinks,
+		},
+		{
+			name:   "mostly links",
+			body:   "see https://example.com/a/very/long/path/to/something",
+			action: ActionHold,
+			rule:   RuleLinks,
+		},
+		{
+			name:   "under the velocity limit",
+			body:   "Hello",
+			recent: burst(9, time.Minute),
+			action: ActionAllow,
+		},
+		{
+			name:   "over the velocity limit",
+			body:   "Hello",
+			recent: burst(10, time.Minute),
+			action: ActionReject,
+			rule:   RuleVelocity,
+		},
+		{
+			name:   "velocity wins over links",
+			body:   "https://example.com/a/very/long/path/to/something",
+			recent: burst(10, time.Minute),
+			action: ActionReject,
+			rule:   RuleVelocity,
+		},
+		{
+			name:   "reject wins over hold",
+			config: func(c *Config) { c.LinkAction = ActionHold; c.DuplicateAction = ActionReject },
+			body:   "https://example.com/a/very/long/path/to/something",
+			recent: []Post{{Body: "https://example.com/a/very/long/path/to/something", CreatedAt: ago(time.Minute)}},
+			action: ActionReject,
+			rule:   RuleDuplicate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			got := config.Check(tt.body, tt.recent, now)
+			if got.Action != tt.action || got.Rule != tt.rule {
+				t.Errorf("Check() = %+v, want action %q, rule %q", got, tt.action, tt.rule)
+			}
+		})
+	}
+}
+
+func TestCheckVelocityRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := Config{VelocityLimit: 2, VelocityWindow: time.Minute, VelocityAction: ActionReject}
+	recent := []Post{
+		{Body: "a", CreatedAt: now.Add(-40 * time.Second)},
+		{Body: "b", CreatedAt: now.Add(-10 * time.Second)},
+		{Body: "c", CreatedAt: now.Add(-2 * time.Minute)},
+	}
+	got := config.Check("d", recent, now)
+	if got.Action != ActionReject || got.RetryAfter != 20*time.Second {
+		t.Errorf("Check() = %+v, want a reject with RetryAfter 20s", got)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Hello, World!", want: "hello world"},
+		{text: "  spaced \t out\n", want: "spaced out"},
+		{text: "mask **** kept", want: "mask kept"},
+		{text: "!!!", want: ""},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.text); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
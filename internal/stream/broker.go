@@ -0,0 +1,202 @@
+// Package stream fans events out to live subscribers, such as Server-Sent
+// Events connections. Recent events are kept so a client that reconnects
+// can pick up where it left off.
+package stream
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Event struct {
+	// ID increases with every event published. IDs start from the broker's
+	// creation time, so they keep increasing across restarts.
+	ID       uint64
+	Type     string
+	AuthorID uuid.UUID
+	// ThreadID is the chirp the event belongs to.
+	ThreadID uuid.UUID
+	// Hashtags are lowercase and without the leading #.
+	Hashtags []string
+	// Mentions are the lowercase email addresses mentioned as @address.
+	Mentions []string
+	// Removal marks an event that takes back an earlier one, such as a
+	// deletion. What was removed may no longer be known, so removals
+	// aren't held to hashtag and mention filters.
+	Removal bool
+	// Payload is whatever the publisher attached, such as the chirp.
+	Payload any
+}
+
+// Filter picks the events a subscriber receives. A zero Filter matches
+// everything.
+type Filter struct {
+	AuthorID uuid.NullUUID
+	ThreadID uuid.NullUUID
+	Hashtag  string
+	Mention  string
+}
+
+func (f Filter) Match(event Event) bool {
+	if f.AuthorID.Valid && event.AuthorID != f.AuthorID.UUID {
+		return false
+	}
+	if f.ThreadID.Valid && event.ThreadID != f.ThreadID.UUID {
+		return false
+	}
+	if f.Hashtag != "" && !event.Removal && !contains(event.Hashtags, f.Hashtag) {
+		return false
+	}
+	if f.Mention != "" && !event.Removal && !contains(event.Mentions, f.Mention) {
+		return false
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Broker keeps a bounded replay buffer and the current subscribers. It
+// never waits on a subscriber: one whose buffer is full is dropped, and can
+// reconnect and resume from the replay buffer.
+type Broker struct {
+	mu          sync.Mutex
+	nextID      uint64
+	replay      []Event
+	replaySize  int
+	bufferSize  int
+	subscribers map[*Subscription]bool
+}
+
+type Subscription struct {
+	// Events delivers matching events. It is closed when the subscription
+	// is closed or dropped for falling behind.
+	Events <-chan Event
+
+	events chan Event
+	filter Filter
+	broker *Broker
+}
+
+// NewBroker returns a broker that keeps the last replaySize events and gives
+// each subscriber a buffer of bufferSize events.
+func NewBroker(replaySize, bufferSize int) *Broker {
+	return &Broker{
+		nextID:      uint64(time.Now().UnixMilli()) << 10,
+		replaySize:  replaySize,
+		bufferSize:  bufferSize,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+// Publish assigns the event an ID, stores it for replay and hands it to
+// every matching subscriber.
+func (b *Broker) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event.ID = b.nextID
+	b.replay = append(b.replay, event)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe starts a subscription. If lastEventID is non-zero, the matching
+// events published after it are returned to be sent first; complete is
+// false when some of them are no longer in the replay buffer.
+func (b *Broker) Subscribe(filter Filter, lastEventID uint64) (sub *Subscription, missed []Event, complete bool) {
+	events := make(chan Event, b.bufferSize)
+	sub = &Subscription{Events: events, events: events, filter: filter, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if lastEventID != 0 {
+		// Only an ID that is still buffered can be resumed from. Older
+		// ones, and ones issued by another replica's broker, can't.
+		complete = lastEventID == b.nextID
+		for _, event := range b.replay {
+			if event.ID == lastEventID {
+				complete = true
+			}
+			if event.ID > lastEventID && filter.Match(event) {
+				missed = append(missed, event)
+			}
+		}
+	}
+	b.subscribers[sub] = true
+	return sub, missed, complete
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+func (b *Broker) drop(sub *Subscription) {
+	if b.subscribers[sub] {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{N}_]+)`)
+
+// Hashtags returns the distinct hashtags in body, lowercased and without
+// the #.
+func Hashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w.+-])@([\w.%+-]+@[\w-]+(?:\.[\w-]+)+)`)
+
+// Mentions returns the distinct email addresses mentioned in body as
+// @address, lowercased.
+func Mentions(body string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		mention := strings.ToLower(match[1])
+		if !seen[mention] {
+			seen[mention] = true
+			mentions = append(mentions, mention)
+		}
+	}
+	return mentions
+}
@@ -0,0 +1,179 @@
+package stream
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFilterMatch(t *testing.T) {
+	author := uuid.New()
+	chirp := uuid.New()
+	event := Event{
+		AuthorID: author,
+		ThreadID: chirp,
+		Hashtags: []string{"go", "chirpy"},
+		Mentions: []string{"a@example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		removal bool
+		want    bool
+	}{
+		{name: "zero filter", filter: Filter{}, want: true},
+		{name: "author", filter: Filter{AuthorID: uuid.NullUUID{UUID: author, Valid: true}}, want: true},
+		{name: "other author", filter: Filter{AuthorID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}, want: false},
+		{name: "thread", filter: Filter{ThreadID: uuid.NullUUID{UUID: chirp, Valid: true}}, want: true},
+		{name: "other thread", filter: Filter{ThreadID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}, want: false},
+		{name: "hashtag", filter: Filter{Hashtag: "chirpy"}, want: true},
+		{name: "other hashtag", filter: Filter{Hashtag: "rust"}, want: false},
+		{name: "mention", filter: Filter{Mention: "a@example.com"}, want: true},
+		{name: "other mention", filter: Filter{Mention: "b@example.com"}, want: false},
+		{name: "all must match", filter: Filter{AuthorID: uuid.NullUUID{UUID: author, Valid: true}, Hashtag: "rust"}, want: false},
+		{name: "removal skips hashtag", filter: Filter{Hashtag: "rust"}, removal: true, want: true},
+		{name: "removal skips mention", filter: Filter{Mention: "b@example.com"}, removal: true, want: true},
+		{name: "removal still checks author", filter: Filter{AuthorID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}, removal: true, want: false},
+		{name: "removal still checks thread", filter: Filter{ThreadID: uuid.NullUUID{UUID: uuid.New(), Valid: true}}, removal: true, want: false},
+	}
+	for _, tt := range tests {
+		event := event
+		event.Removal = tt.removal
+		if got := tt.filter.Match(event); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(3, 10)
+	var ids []uint64
+	for _, tag := range []string{"a", "b", "a", "b", "a"} {
+		ids = append(ids, b.Publish(Event{Hashtags: []string{tag}}).ID)
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("event IDs %v don't increase", ids)
+		}
+	}
+
+	// The buffer holds the last three events: ids[2], ids[3] and ids[4].
+	tests := []struct {
+		name         string
+		filter       Filter
+		lastEventID  uint64
+		wantMissed   []uint64
+		wantComplete bool
+	}{
+		{name: "new subscriber", lastEventID: 0, wantComplete: true},
+		{name: "up to date", lastEventID: ids[4], wantComplete: true},
+		{name: "resume", lastEventID: ids[2], wantMissed: []uint64{ids[3], ids[4]}, wantComplete: true},
+		{name: "resume filtered", filter: Filter{Hashtag: "a"}, lastEventID: ids[2], wantMissed: []uint64{ids[4]}, wantComplete: true},
+		{name: "evicted", lastEventID: ids[0], wantMissed: []uint64{ids[2], ids[3], ids[4]}, wantComplete: false},
+		{name: "unknown", lastEventID: 42, wantMissed: []uint64{ids[2], ids[3], ids[4]}, wantComplete: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed, complete := b.Subscribe(tt.filter, tt.lastEventID)
+			defer sub.Close()
+			var got []uint64
+			for _, event := range missed {
+				got = append(got, event.ID)
+			}
+			if !slices.Equal(got, tt.wantMissed) {
+				t.Errorf("missed = %v, want %v", got, tt.wantMissed)
+			}
+			if complete != tt.wantComplete {
+				t.Errorf("complete = %v, want %v", complete, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestBrokerDelivers(t *testing.T) {
+	b := NewBroker(10, 10)
+	all, _, _ := b.Subscribe(Filter{}, 0)
+	defer all.Close()
+	tagged, _, _ := b.Subscribe(Filter{Hashtag: "go"}, 0)
+	defer tagged.Close()
+
+	first := b.Publish(Event{Hashtags: []string{"go"}})
+	second := b.Publish(Event{Hashtags: []string{"rust"}})
+
+	if got := <-all.Events; got.ID != first.ID {
+		t.Errorf("first event = %d, want %d", got.ID, first.ID)
+	}
+	if got := <-all.Events; got.ID != second.ID {
+		t.Errorf("second event = %d, want %d", got.ID, second.ID)
+	}
+	if got := <-tagged.Events; got.ID != first.ID {
+		t.Errorf("tagged event = %d, want %d", got.ID, first.ID)
+	}
+	if len(tagged.Events) != 0 {
+		t.Error("filtered subscriber received an event it doesn't match")
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(10, 2)
+	slow, _, _ := b.Subscribe(Filter{}, 0)
+	fast, _, _ := b.Subscribe(Filter{}, 0)
+	defer fast.Close()
+
+	for range 3 {
+		b.Publish(Event{})
+		<-fast.Events
+	}
+
+	// The slow subscriber gets what fitted in its buffer, then sees the
+	// channel closed.
+	received := 0
+	for range slow.Events {
+		received++
+	}
+	if received != 2 {
+		t.Errorf("slow subscriber received %d events, want 2", received)
+	}
+	if len(fast.Events) != 0 {
+		t.Error("fast subscriber has an unexpected event")
+	}
+
+	// Closing a dropped subscription again is harmless.
+	slow.Close()
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	b := NewBroker(10, 10)
+	sub, _, _ := b.Subscribe(Filter{}, 0)
+	sub.Close()
+	sub.Close()
+	if _, ok := <-sub.Events; ok {
+		t.Error("Events is still open after Close")
+	}
+	b.Publish(Event{})
+}
+
+func TestHashtagsAndMentions(t *testing.T) {
+	tests := []struct {
+		body         string
+		wantHashtags []string
+		wantMentions []string
+	}{
+		{body: "no tags here", wantHashtags: []string{}, wantMentions: []string{}},
+		{body: "#Go and #go and #chirpy_2", wantHashtags: []string{"go", "chirpy_2"}, wantMentions: []string{}},
+		{body: "#café", wantHashtags: []string{"café"}, wantMentions: []string{}},
+		{body: "hi @A@Example.com and @a@example.com", wantHashtags: []string{}, wantMentions: []string{"a@example.com"}},
+		{body: "mail me at me@example.com", wantHashtags: []string{}, wantMentions: []string{}},
+		{body: "@b@example.org: #news", wantHashtags: []string{"news"}, wantMentions: []string{"b@example.org"}},
+	}
+	for _, tt := range tests {
+		if got := Hashtags(tt.body); !slices.Equal(got, tt.wantHashtags) {
+			t.Errorf("Hashtags(%q) = %q, want %q", tt.body, got, tt.wantHashtags)
+		}
+		if got := Mentions(tt.body); !slices.Equal(got, tt.wantMentions) {
+			t.Errorf("Mentions(%q) = %q, want %q", tt.body, got, tt.wantMentions)
+		}
+	}
+}
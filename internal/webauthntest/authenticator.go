@@ -0,0 +1,210 @@
+// Package webauthntest provides a software WebAuthn authenticator so the
+// passkey endpoints can be exercised from Go tests without a browser or a
+// hardware security key.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+)
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// Authenticator is a software passkey authenticator that answers
+// registration and login challenges with ES256 credentials and "none"
+// attestation, just like a platform authenticator would.
+type Authenticator struct {
+	Origin      string
+	credentials []*credential
+}
+
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+// Clone returns an authenticator holding copies of the same private keys and
+// counters, which is how a cloned hardware key looks to the relying party.
+func (a *Authenticator) Clone() *Authenticator {
+	clone := &Authenticator{Origin: a.Origin}
+	for _, c := range a.credentials {
+		copied := *c
+		clone.credentials = append(clone.credentials, &copied)
+	}
+	return clone
+}
+
+// Register answers the JSON returned by a registration begin endpoint
+// ({"publicKey": {...}}) and returns the attestation JSON to post back.
+func (a *Authenticator) Register(options []byte) ([]byte, error) {
+	var creation protocol.CredentialCreation
+	if err := json.Unmarshal(options, &creation); err != nil {
+		return nil, err
+	}
+	opts := creation.Response
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	userHandle, ok := opts.User.ID.(string)
+	if !ok {
+		return nil, errors.New("webauthntest: unexpected user id in creation options")
+	}
+	handle, err := base64.RawURLEncoding.DecodeString(userHandle)
+	if err != nil {
+		return nil, err
+	}
+	cred := &credential{
+		id:         id,
+		rpID:       opts.RelyingParty.ID,
+		userHandle: handle,
+		key:        key,
+	}
+
+	coseKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord: key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	attested := make([]byte, 16, 16+2+len(id)+len(coseKey))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, coseKey...)
+	authData := cred.authenticatorData(flagUserPresent|flagUserVerified|flagAttestedData, attested)
+
+	attestationObject, err := webauthncbor.Marshal(struct {
+		Format       string         `cbor:"fmt"`
+		AttStatement map[string]any `cbor:"attStmt"`
+		AuthData     []byte         `cbor:"authData"`
+	}{
+		Format:       "none",
+		AttStatement: map[string]any{},
+		AuthData:     authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+	clientData, err := a.clientData("webauthn.create", opts.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	a.credentials = append(a.credentials, cred)
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(id),
+		"rawId": base64.RawURLEncoding.EncodeToString(id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	})
+}
+
+// Login answers the JSON returned by a login begin endpoint and returns the
+// assertion JSON to post back. Every assertion increments the credential's
+// signature counter.
+func (a *Authenticator) Login(options []byte) ([]byte, error) {
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(options, &assertion); err != nil {
+		return nil, err
+	}
+	opts := assertion.Response
+
+	cred := a.find(opts.RelyingPartyID, opts.AllowedCredentials)
+	if cred == nil {
+		return nil, errors.New("webauthntest: no matching credential")
+	}
+	cred.signCount++
+	authData := cred.authenticatorData(flagUserPresent|flagUserVerified, nil)
+	clientData, err := a.clientData("webauthn.get", opts.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	digest := sha256.Sum256(signed)
+	signature, err := ecdsa.SignASN1(rand.Reader, cred.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(cred.id),
+		"rawId": base64.RawURLEncoding.EncodeToString(cred.id),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString(cred.userHandle),
+		},
+	})
+}
+
+func (a *Authenticator) find(rpID string, allowed []protocol.CredentialDescriptor) *credential {
+	for _, c := range a.credentials {
+		if rpID != "" && c.rpID != rpID {
+			continue
+		}
+		if len(allowed) == 0 {
+			return c
+		}
+		for _, descriptor := range allowed {
+			if string(descriptor.CredentialID) == string(c.id) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge protocol.URLEncodedBase64) ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   challenge.String(),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (c *credential) authenticatorData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(c.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, c.signCount)
+	return append(data, attested...)
+}
@@ -0,0 +1,111 @@
+// Package webhooks sends signed event notifications to endpoints that
+// integrators register. Bodies are signed the same way as the webhooks
+// Chirpy receives, so one verifier works for both directions.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+)
+
+const (
+	// EventIDHeader carries the event's ID, which stays the same across
+	// retries and redeliveries so receivers can deduplicate.
+	EventIDHeader   = "X-Webhook-Id"
+	EventTypeHeader = "X-Webhook-Event"
+)
+
+// Delivery is one event to send to one endpoint.
+type Delivery struct {
+	URL       string
+	Secret    string
+	EventID   uuid.UUID
+	EventType string
+	Payload   []byte
+}
+
+// NewClient returns a client for sending webhooks. It gives up on a request
+// after timeout and doesn't follow redirects, so an endpoint can't point
+// deliveries somewhere else; a redirect counts as a failed attempt.
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+var defaultClient = NewClient(0)
+
+type Sender struct {
+	// Timeout bounds each attempt, including reading the response.
+	Timeout time.Duration
+	// Client defaults to one from NewClient that relies on Timeout.
+	Client *http.Client
+}
+
+// Send makes one delivery attempt. It returns the response status, or 0 if
+// there was no response, and an error unless the endpoint answered 2xx.
+func (s Sender) Send(ctx context.Context, delivery Delivery, now time.Time) (int, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1")
+	req.Header.Set(EventIDHeader, delivery.EventID.String())
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(auth.WebhookTimestampHeader, fmt.Sprint(now.Unix()))
+	req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(delivery.Secret, now, delivery.Payload))
+
+	client := s.Client
+	if client == nil {
+		client = defaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("endpoint answered %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// Backoff spaces out retries: Base after the first failure, doubling after
+// each one after that up to Max.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Delay returns how long to wait after the given number of failed attempts.
+// Up to a tenth is added at random so endpoints that fail together don't
+// get retried in lockstep.
+func (b Backoff) Delay(attempts int) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempts && delay < b.Max; i++ {
+		delay *= 2
+	}
+	if delay > b.Max {
+		delay = b.Max
+	}
+	return delay + rand.N(delay/10+1)
+}
@@ -0,0 +1,132 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+)
+
+func TestSend(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	eventID := uuid.New()
+	payload := []byte(`{"id":"1"}`)
+
+	tests := []struct {
+		name       string
+		status     int
+		redirect   bool
+		wantStatus int
+		wantErr    bool
+	}{
+		{name: "ok", status: http.StatusOK, wantStatus: http.StatusOK},
+		{name: "no content", status: http.StatusNoContent, wantStatus: http.StatusNoContent},
+		{name: "server error", status: http.StatusInternalServerError, wantStatus: http.StatusInternalServerError, wantErr: true},
+		{name: "not found", status: http.StatusNotFound, wantStatus: http.StatusNotFound, wantErr: true},
+		{name: "redirect is not followed", redirect: true, wantStatus: http.StatusFound, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got *http.Request
+			var body []byte
+			redirected := false
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/elsewhere" {
+					redirected = true
+					return
+				}
+				got = r
+				body, _ = io.ReadAll(r.Body)
+				if tc.redirect {
+					http.Redirect(w, r, "/elsewhere", http.StatusFound)
+					return
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			status, err := Sender{Timeout: time.Second}.Send(context.Background(), Delivery{
+				URL:       srv.URL,
+				Secret:    "secret",
+				EventID:   eventID,
+				EventType: "chirp.created",
+				Payload:   payload,
+			}, now)
+			if status != tc.wantStatus {
+				t.Errorf("status = %d, want %d", status, tc.wantStatus)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("err = %v, want error: %v", err, tc.wantErr)
+			}
+			if redirected {
+				t.Error("the redirect was followed")
+			}
+			if got == nil {
+				t.Fatal("no request was sent")
+			}
+			if id := got.Header.Get(EventIDHeader); id != eventID.String() {
+				t.Errorf("%s = %q, want %q", EventIDHeader, id, eventID)
+			}
+			if typ := got.Header.Get(EventTypeHeader); typ != "chirp.created" {
+				t.Errorf("%s = %q, want chirp.created", EventTypeHeader, typ)
+			}
+			if ts := got.Header.Get(auth.WebhookTimestampHeader); ts != strconv.FormatInt(now.Unix(), 10) {
+				t.Errorf("%s = %q, want %d", auth.WebhookTimestampHeader, ts, now.Unix())
+			}
+			err = auth.VerifyWebhookSignature(got.Header, body, []string{"secret"}, now, time.Minute)
+			if err != nil {
+				t.Errorf("signature doesn't verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	status, err := Sender{Timeout: 50 * time.Millisecond}.Send(context.Background(), Delivery{URL: srv.URL}, time.Now())
+	if err == nil || status != 0 {
+		t.Errorf("Send = %d, %v; want 0 and an error", status, err)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Base: 30 * time.Second, Max: 6 * time.Hour}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 5, want: 8 * time.Minute},
+		{attempts: 10, want: 256 * time.Minute},
+		{attempts: 11, want: 6 * time.Hour},
+		{attempts: 50, want: 6 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(strconv.Itoa(tc.attempts), func(t *testing.T) {
+			// The jitter is random, so check the bounds over a few draws.
+			for range 100 {
+				got := backoff.Delay(tc.attempts)
+				if got < tc.want || got > tc.want+tc.want/10 {
+					t.Fatalf("Delay(%d) = %v, want between %v and %v", tc.attempts, got, tc.want, tc.want+tc.want/10)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runEvery runs job straight away and then once per interval until ctx is
+// cancelled. Jobs log their own errors.
+func runEvery(ctx context.Context, interval time.Duration, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runEveryOrWhen is runEvery, except that job also runs as soon as wake
+// receives rather than waiting for the next interval.
+func runEveryOrWhen(ctx context.Context, interval time.Duration, wake <-chan struct{}, job func(context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/mailer"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+func (cfg *apiConfig) requestMagicLink(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&params)
+	if err != nil || params.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// A line break would end the To header and start another.
+	if strings.ContainsAny(params.Email, "\r\n") {
+		respondWithError(w, http.StatusBadRequest, "Invalid email address")
+		return
+	}
+
+	// Always answer 202, straight away, and do the rest in the background so
+	// neither the status nor the response time shows which email addresses
+	// have accounts.
+	fingerprint := auth.DeviceFingerprint(req.Header)
+	go cfg.sendMagicLink(context.WithoutCancel(req.Context()), params.Email, fingerprint)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendMagicLink emails a login link to the account with email, if there is
+// one. The link only works from the device with fingerprint.
+func (cfg *apiConfig) sendMagicLink(ctx context.Context, email, fingerprint string) {
+	user, err := cfg.db.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = cfg.db.CreateMagicLink(ctx, database.CreateMagicLinkParams{
+		TokenHash:       auth.HashToken(token),
+		UserID:          user.ID,
+		FingerprintHash: fingerprint,
+		ExpiresAt:       time.Now().Add(magicLinkTTL),
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/api/login/magic/verify?token=%s", cfg.baseURL, url.QueryEscape(token))
+	err = cfg.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy login link",
+		Body: fmt.Sprintf("Click the link below to log in to Chirpy. It expires in %d minutes and only works on the device you requested it from.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.",
+			int(magicLinkTTL.Minutes()), link),
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// deleteExpiredMagicLinks removes links that can no longer be used.
+func (cfg *apiConfig) deleteExpiredMagicLinks(ctx context.Context) {
+	deleted, err := cfg.db.DeleteExpiredMagicLinks(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if deleted > 0 {
+		fmt.Printf("deleted %d expired magic links\n", deleted)
+	}
+}
+
+func (cfg *apiConfig) verifyMagicLink(w http.ResponseWriter, req *http.Request) {
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	link, err := cfg.db.ConsumeMagicLink(req.Context(), database.ConsumeMagicLinkParams{
+		TokenHash:       auth.HashToken(token),
+		FingerprintHash: auth.DeviceFingerprint(req.Header),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Login link is invalid, expired, already used or was requested from another device")
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(req.Context(), link.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	cfg.respondWithLogin(w, req, user)
+}
@@ -1,32 +1,57 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"slices"
 	"sort"
-	"strings"
+	"strconv"
+	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"os"
+	"os/signal"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/billing"
 	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+	"github.com/ifeanyibatman/chirpy/internal/events"
+	"github.com/ifeanyibatman/chirpy/internal/mailer"
+	"github.com/ifeanyibatman/chirpy/internal/moderation"
+	"github.com/ifeanyibatman/chirpy/internal/profanity"
+	"github.com/ifeanyibatman/chirpy/internal/ratelimit"
+	"github.com/ifeanyibatman/chirpy/internal/spam"
+	"github.com/ifeanyibatman/chirpy/internal/stream"
 	"github.com/joho/godotenv"
 
 	_ "github.com/lib/pq"
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Body           string     `json:"body"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ContentWarning *string    `json:"content_warning"`
+	Sensitive      bool       `json:"sensitive"`
+	EditedAt       *time.Time `json:"edited_at"`
+	PinnedAt       *time.Time `json:"pinned_at"`
+	// Scheduled is set on the author's own chirps that haven't been
+	// published yet.
+	Scheduled bool `json:"scheduled,omitempty"`
+	// Collapsed is set when the body has been withheld because of the
+	// viewer's sensitive content preference.
+	Collapsed bool `json:"collapsed,omitempty"`
 }
 
 type LoginResponse struct {
@@ -50,11 +75,51 @@ type User struct {
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	sqlDB          *sql.DB
 	platform       string
 	jwt_secret     string
-	polka_secret   string
+	passwordPolicy auth.PasswordPolicy
+	webAuthn       *webauthn.WebAuthn
+	mailer         mailer.Sender
+	baseURL        string
+
+	deletionGracePeriod time.Duration
+	dataExportTTL       time.Duration
+	// dataExportSlots bounds how many exports are built at once.
+	dataExportSlots chan struct{}
+
+	subscriptionGracePeriod time.Duration
+
+	rateLimiter    *ratelimit.Limiter
+	rateLimits     map[string]ratelimit.Limit
+	trustedProxies ratelimit.TrustedProxies
+	plans          entitlements.Plans
+
+	billingProviders map[string]billing.Provider
+
+	spam      spam.Config
+	moderator moderation.Moderator
+
+	profanityWordsFile string
+	profanityFilter    *profanity.Holder
+	profanityReloadMu  sync.Mutex
+	rescanJobs         *rescanJobRegistry
+
+	events          events.Bus
+	chirpStream     *stream.Broker
+	webhooksPending chan struct{}
+
+	// shutdown is cancelled when the server starts shutting down, ending
+	// streams and WebSocket connections. liveConnections counts the
+	// WebSocket connections, which the server no longer tracks itself.
+	shutdown        context.Context
+	liveConnections sync.WaitGroup
 }
 
+// shutdownTimeout is how long open requests and connections get to finish
+// when the server is stopped.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 
 	godotenv.Load()
@@ -65,33 +130,291 @@ func main() {
 	}
 	apiCfg := &apiConfig{}
 	apiCfg.db = database.New(db)
+	apiCfg.sqlDB = db
 	apiCfg.platform = os.Getenv("PLATFORM")
 	apiCfg.jwt_secret = os.Getenv("JWT_SECRET")
-	apiCfg.polka_secret = os.Getenv("POLKA_SECRET")
+	apiCfg.billingProviders = loadBillingProviders()
+	apiCfg.passwordPolicy, err = loadPasswordPolicy()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiCfg.baseURL = os.Getenv("BASE_URL")
+	if apiCfg.baseURL == "" {
+		apiCfg.baseURL = "http://localhost:8080"
+	}
+	mailDir := os.Getenv("MAIL_DIR")
+	if mailDir == "" {
+		mailDir = "mail"
+	}
+	apiCfg.mailer = mailer.FileSender{Dir: mailDir, From: "Chirpy <no-reply@chirpy.local>"}
+	apiCfg.webAuthn, err = loadWebAuthn()
+	if err != nil {
+		fmt.Println(err)
+	}
+	apiCfg.deletionGracePeriod = 30 * 24 * time.Hour
+	if grace, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD")); err == nil {
+		apiCfg.deletionGracePeriod = grace
+	}
+	apiCfg.profanityWordsFile = os.Getenv("PROFANITY_WORDS_FILE")
+	filter, err := apiCfg.loadProfanityFilter(context.Background())
+	if err != nil {
+		// Start with the seeded word list rather than no filter at all; the
+		// full list is loaded again on the next change to banned words.
+		fmt.Println(err)
+		filter = profanity.New(profanity.DefaultEntries())
+	}
+	apiCfg.profanityFilter = profanity.NewHolder(filter)
+	apiCfg.moderator = apiCfg.loadModerator()
+	apiCfg.rescanJobs = newRescanJobRegistry()
+	apiCfg.chirpStream = stream.NewBroker(streamReplaySize, streamBufferSize)
+	apiCfg.rateLimiter = ratelimit.New()
+	apiCfg.rateLimits = loadRateLimits()
+	apiCfg.plans = loadPlans()
+	apiCfg.trustedProxies, err = ratelimit.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		fmt.Println(err)
+	}
+	apiCfg.spam = loadSpamConfig()
+	apiCfg.dataExportTTL = 24 * time.Hour
+	if ttl, err := time.ParseDuration(os.Getenv("DATA_EXPORT_TTL")); err == nil {
+		apiCfg.dataExportTTL = ttl
+	}
+	dataExportWorkers := 2
+	envInt("DATA_EXPORT_WORKERS", &dataExportWorkers)
+	apiCfg.dataExportSlots = make(chan struct{}, max(dataExportWorkers, 1))
+	apiCfg.subscriptionGracePeriod = 3 * 24 * time.Hour
+	envDuration("SUBSCRIPTION_GRACE_PERIOD", &apiCfg.subscriptionGracePeriod)
+	if len(os.Args) > 1 {
+		if err := apiCfg.runCommand(os.Args[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+	apiCfg.events, err = loadEventBus(db, dbURL)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiCfg.webhooksPending = make(chan struct{}, 1)
+	apiCfg.subscribeEvents()
+
 	serveMux := http.NewServeMux()
 	srv := http.Server{
 		Addr:    ":8080",
 		Handler: serveMux,
 	}
+	shutdown, closeConnections := context.WithCancel(context.Background())
+	apiCfg.shutdown = shutdown
+	srv.RegisterOnShutdown(closeConnections)
 
 	serveMux.Handle("/app/", http.StripPrefix("/app/", apiCfg.middlewareMetricsInc(http.FileServer(http.Dir(".")))))
 	serveMux.HandleFunc("GET /api/healthz", healthz)
 	//Chirps
-	serveMux.HandleFunc("GET /api/chirps", apiCfg.getChirps)
-	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirp)
-	serveMux.HandleFunc("POST /api/chirps", apiCfg.createChirp)
-	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirp)
+	serveMux.HandleFunc("GET /api/chirps", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getChirps))
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getChirp))
+	serveMux.HandleFunc("GET /api/stream/chirps", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.streamChirps))
+	serveMux.HandleFunc("GET /api/stream/ws", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.chirpSocket))
+	serveMux.HandleFunc("POST /api/chirps", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.createChirp)))
+	serveMux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.editChirp)))
+	serveMux.HandleFunc("PUT /api/chirps/{chirpID}/pin", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.pinChirp)))
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.unpinChirp)))
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.deleteChirp)))
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/report", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.reportChirp)))
 	//Users
-	serveMux.HandleFunc("POST /api/users", apiCfg.createUser)
-	serveMux.HandleFunc("POST /api/login", apiCfg.login)
-	serveMux.HandleFunc("POST /api/refresh", apiCfg.refreshToken)
-	serveMux.HandleFunc("POST /api/revoke", apiCfg.revokeToken)
-	serveMux.HandleFunc("PUT /api/users", apiCfg.updateUser)
-	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.upgradeUserToChirpyRed)
+	serveMux.HandleFunc("POST /api/users", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.createUser))
+	serveMux.HandleFunc("POST /api/login", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.login))
+	serveMux.HandleFunc("POST /api/refresh", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.refreshToken))
+	// Revoking takes a refresh token, and suspending an account already
+	// revokes all of them, so there's nothing for middlewareBlockSuspended to
+	// check.
+	serveMux.HandleFunc("POST /api/revoke", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.revokeToken))
+	serveMux.HandleFunc("PUT /api/users", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.updateUser)))
+	serveMux.HandleFunc("DELETE /api/users/me", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.deleteAccount)))
+	serveMux.HandleFunc("POST /api/users/me/export", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.requestDataExport)))
+	serveMux.HandleFunc("GET /api/users/me/export/{exportID}", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getDataExport))
+	serveMux.HandleFunc("GET /api/users/me/preferences", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getPreferences))
+	serveMux.HandleFunc("PUT /api/users/me/preferences", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.updatePreferences)))
+	serveMux.HandleFunc("GET /api/users/me/entitlements", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getEntitlements))
+	serveMux.HandleFunc("POST /api/users/me/redeem", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.redeemPromoCode)))
+	serveMux.HandleFunc("GET /api/users/me/subscription", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getSubscription))
+	serveMux.HandleFunc("GET /api/users/me/blocks", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getBlockedUsers))
+	serveMux.HandleFunc("GET /api/users/me/mutes", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getMutedUsers))
+	serveMux.HandleFunc("POST /api/users/{userID}/block", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.blockUser)))
+	serveMux.HandleFunc("DELETE /api/users/{userID}/block", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.unblockUser)))
+	serveMux.HandleFunc("POST /api/users/{userID}/mute", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.muteUser)))
+	serveMux.HandleFunc("DELETE /api/users/{userID}/mute", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.unmuteUser)))
+	serveMux.HandleFunc("GET /api/users/me/passkeys", apiCfg.middlewareRateLimit(rateLimitRead, apiCfg.getPasskeys))
+	serveMux.HandleFunc("DELETE /api/users/me/passkeys/{credentialID}", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.deletePasskey)))
+	serveMux.HandleFunc("POST /api/users/me/passkeys/register/begin", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.beginPasskeyRegistration)))
+	serveMux.HandleFunc("POST /api/users/me/passkeys/register/finish", apiCfg.middlewareRateLimit(rateLimitWrite, apiCfg.middlewareBlockSuspended(apiCfg.finishPasskeyRegistration)))
+	serveMux.HandleFunc("POST /api/login/magic", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.requestMagicLink))
+	serveMux.HandleFunc("GET /api/login/magic/verify", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.verifyMagicLink))
+	serveMux.HandleFunc("POST /api/login/passkey/begin", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.beginPasskeyLogin))
+	serveMux.HandleFunc("POST /api/login/passkey/finish", apiCfg.middlewareRateLimit(rateLimitAuth, apiCfg.finishPasskeyLogin))
+	serveMux.HandleFunc("POST /api/polka/webhooks", apiCfg.middlewareRateLimit(rateLimitWebhook, apiCfg.billingWebhook("polka")))
 	//Admin
-	serveMux.HandleFunc("GET /admin/metrics", apiCfg.metrics)
-	serveMux.HandleFunc("POST /admin/reset", apiCfg.resetMetrics)
-	srv.ListenAndServe()
+	serveMux.HandleFunc("GET /admin/metrics", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.metrics))
+	serveMux.HandleFunc("POST /admin/reset", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.resetMetrics))
+	serveMux.HandleFunc("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.updateUserRole))
+	serveMux.HandleFunc("POST /admin/users/{userID}/gift", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.giftSubscription))
+	serveMux.HandleFunc("GET /admin/promo-codes", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getPromoCodes))
+	serveMux.HandleFunc("POST /admin/promo-codes", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.createPromoCode))
+	serveMux.HandleFunc("GET /admin/promo-codes/{codeID}/redemptions", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getPromoRedemptions))
+	serveMux.HandleFunc("GET /admin/moderation/reports", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.getReports))
+	serveMux.HandleFunc("GET /admin/moderation/reports/{reportID}", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.getReport))
+	serveMux.HandleFunc("POST /admin/moderation/reports/{reportID}/resolve", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.resolveReport))
+	serveMux.HandleFunc("GET /admin/moderation/chirps", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.getModerationChirps))
+	serveMux.HandleFunc("PUT /admin/moderation/chirps/{chirpID}/status", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.updateChirpModerationStatus))
+	serveMux.HandleFunc("PUT /admin/moderation/chirps/{chirpID}/labels", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.updateChirpLabels))
+	serveMux.HandleFunc("PUT /admin/moderation/users/{userID}/suspension", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.updateUserSuspension))
+	serveMux.HandleFunc("DELETE /admin/moderation/users/{userID}/suspension", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.deleteUserSuspension))
+	serveMux.HandleFunc("PUT /admin/moderation/users/{userID}/shadow-ban", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.updateUserShadowBan))
+	serveMux.HandleFunc("DELETE /admin/moderation/users/{userID}/shadow-ban", apiCfg.middlewareRequireRole(auth.RoleModerator, apiCfg.deleteUserShadowBan))
+	serveMux.HandleFunc("GET /admin/moderation/actions", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getModerationActions))
+	serveMux.HandleFunc("GET /admin/moderation/words", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getBannedWords))
+	serveMux.HandleFunc("POST /admin/moderation/words", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.createBannedWord))
+	serveMux.HandleFunc("DELETE /admin/moderation/words/{word}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.deleteBannedWord))
+	serveMux.HandleFunc("POST /admin/moderation/words/rescan", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.startRescan))
+	serveMux.HandleFunc("GET /admin/moderation/words/rescan/{jobID}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getRescan))
+	serveMux.HandleFunc("GET /admin/webhooks/events", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getWebhookEvents))
+	serveMux.HandleFunc("POST /admin/webhooks/events/{eventID}/replay", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.replayWebhookEvent))
+	serveMux.HandleFunc("GET /admin/webhooks/endpoints", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getWebhookEndpoints))
+	serveMux.HandleFunc("POST /admin/webhooks/endpoints", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.createWebhookEndpoint))
+	serveMux.HandleFunc("PUT /admin/webhooks/endpoints/{endpointID}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.updateWebhookEndpoint))
+	serveMux.HandleFunc("DELETE /admin/webhooks/endpoints/{endpointID}", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.deleteWebhookEndpoint))
+	serveMux.HandleFunc("GET /admin/webhooks/endpoints/{endpointID}/deliveries", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.getWebhookDeliveries))
+	serveMux.HandleFunc("POST /admin/webhooks/deliveries/{deliveryID}/redeliver", apiCfg.middlewareRequireRole(auth.RoleAdmin, apiCfg.redeliverWebhook))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go runEvery(ctx, time.Hour, apiCfg.purgeDeletedAccounts)
+	go runEvery(ctx, time.Hour, apiCfg.deleteExpiredDataExports)
+	go runEvery(ctx, time.Hour, apiCfg.deleteExpiredWebAuthnSessions)
+	go runEvery(ctx, time.Hour, apiCfg.deleteExpiredMagicLinks)
+	go runEvery(ctx, time.Hour, apiCfg.expireSubscriptions)
+	go runEvery(ctx, 15*time.Second, apiCfg.announceDueChirps)
+	go runEveryOrWhen(ctx, 5*time.Second, apiCfg.webhooksPending, apiCfg.deliverWebhooks)
+
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}()
+	<-ctx.Done()
+	apiCfg.stop(&srv)
+}
+
+// stop shuts the server down, waiting up to shutdownTimeout for requests,
+// streams and WebSocket connections to finish.
+func (cfg *apiConfig) stop(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		fmt.Println(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cfg.liveConnections.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		fmt.Println("gave up waiting for WebSocket connections to close")
+	}
+	if err := cfg.events.Close(); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// loadPasswordPolicy fails if BREACHED_PASSWORDS_FILE is set but can't be
+// read, rather than starting without the breached password check.
+func loadPasswordPolicy() (auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy()
+	if minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+		policy.MinLength = minLength
+	}
+	if maxLength, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH")); err == nil {
+		policy.MaxLength = maxLength
+	}
+	if os.Getenv("PASSWORD_ALLOW_EMAIL") == "true" {
+		policy.DisallowEmail = false
+	}
+	if path := os.Getenv("BREACHED_PASSWORDS_FILE"); path != "" {
+		breached, err := auth.LoadBreachedPasswords(path)
+		if err != nil {
+			return policy, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+func respondPasswordViolations(w http.ResponseWriter, violations []auth.PasswordViolation) {
+	type errorJson struct {
+		Error      string                   `json:"error"`
+		Violations []auth.PasswordViolation `json:"violations"`
+	}
+	dat, err := json.Marshal(errorJson{
+		Error:      "Password does not meet the password policy",
+		Violations: violations,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(dat)
+}
+
+// inTx runs fn with queries that share one transaction, committing it if fn
+// succeeds and rolling it back otherwise.
+func (cfg *apiConfig) inTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(cfg.db.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	type errorJson struct {
+		Error string `json:"error"`
+	}
+	dat, err := json.Marshal(errorJson{Error: msg})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(dat)
+}
+
+// viewerID identifies who is reading on endpoints that don't require
+// authentication. Anonymous requests and invalid tokens get a null ID.
+func (cfg *apiConfig) viewerID(req *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: userID, Valid: true}
 }
 
 func healthz(w http.ResponseWriter, req *http.Request) {
@@ -139,8 +462,11 @@ func (cfg *apiConfig) resetMetrics(w http.ResponseWriter, req *http.Request) {
 
 func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 	type chirp struct {
-		Body   string    `json:"body"`
-		UserID uuid.UUID `json:"user_id"`
+		Body           string     `json:"body"`
+		UserID         uuid.UUID  `json:"user_id"`
+		ContentWarning string     `json:"content_warning"`
+		Sensitive      bool       `json:"sensitive"`
+		PublishAt      *time.Time `json:"publish_at"`
 	}
 
 	type errorJson struct {
@@ -149,7 +475,6 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 	type validity struct {
 		Valid bool `json:"valid"`
 	}
-	profane := []string{"kerfuffle", "sharbert", "fornax"}
 
 	token, err := auth.GetBearerToken(req.Header)
 	if err != nil {
@@ -183,7 +508,14 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	if len(reqChirp.Body) > 140 {
+	capabilities, err := cfg.capabilities(req.Context(), validatedID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if len(reqChirp.Body) > capabilities.MaxChirpLength {
 		w.WriteHeader(http.StatusBadRequest)
 		wrong := errorJson{
 			Error: "Chirp is too long",
@@ -196,20 +528,83 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	words := strings.Split(reqChirp.Body, " ")
-	cleanedWords := []string{}
-	for _, word := range words {
-		if slices.Contains(profane, strings.ToLower(word)) {
-			cleanedWords = append(cleanedWords, "****")
-		} else {
-			cleanedWords = append(cleanedWords, word)
+	if len(reqChirp.ContentWarning) > maxContentWarningLength {
+		respondWithError(w, http.StatusBadRequest, "Content warning is too long")
+		return
+	}
+
+	// A publish time in the past just posts the chirp now.
+	publishAt := sql.NullTime{}
+	if reqChirp.PublishAt != nil && reqChirp.PublishAt.After(time.Now()) {
+		if !capabilities.ScheduledPosts {
+			respondWithError(w, http.StatusForbidden, "Your plan doesn't include scheduled chirps")
+			return
+		}
+		if reqChirp.PublishAt.After(time.Now().Add(maxScheduleAhead)) {
+			respondWithError(w, http.StatusBadRequest, "Chirps can't be scheduled that far ahead")
+			return
+		}
+		publishAt = sql.NullTime{Time: *reqChirp.PublishAt, Valid: true}
+	}
+
+	moderated, err := cfg.moderator.Moderate(req.Context(), moderation.Content{
+		UserID:         validatedID,
+		Body:           reqChirp.Body,
+		ContentWarning: reqChirp.ContentWarning,
+		Sensitive:      reqChirp.Sensitive,
+	})
+	if errors.Is(err, moderation.ErrUnavailable) {
+		fmt.Println(err)
+		respondWithError(w, http.StatusServiceUnavailable, "Chirps can't be checked right now, please try again later")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	moderationStatus := "visible"
+	switch moderated.Decision {
+	case moderation.DecisionReject:
+		respondWithError(w, http.StatusBadRequest, moderated.Reason)
+		return
+	case moderation.DecisionFlag:
+		moderationStatus = "flagged"
+	case moderation.DecisionHold:
+		moderationStatus = "held"
+	}
+	// A moderator that masked the body may have made it longer.
+	if len(moderated.Body) > capabilities.MaxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long once moderated")
+		return
+	}
+
+	verdict, err := cfg.checkSpam(req.Context(), validatedID, moderated.Body, uuid.Nil)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch verdict.Action {
+	case spam.ActionReject:
+		if verdict.Rule == spam.RuleVelocity {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(verdict.RetryAfter.Seconds()))))
+			respondWithError(w, http.StatusTooManyRequests, verdict.Reason)
+			return
 		}
+		respondWithError(w, http.StatusUnprocessableEntity, verdict.Reason)
+		return
+	case spam.ActionHold:
+		moderationStatus = "held"
 	}
-	cleanedChirp := strings.Join(cleanedWords, " ")
 
 	dbChirp, err := cfg.db.CreateChirp(req.Context(), database.CreateChirpParams{
-		Body:   cleanedChirp,
-		UserID: reqChirp.UserID,
+		PublishAt:        publishAt,
+		Body:             moderated.Body,
+		UserID:           reqChirp.UserID,
+		ModerationStatus: moderationStatus,
+		ContentWarning:   contentWarningParam(reqChirp.ContentWarning),
+		Sensitive:        reqChirp.Sensitive,
 	})
 	if err != nil {
 		fmt.Println(err)
@@ -217,13 +612,8 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	res := Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
-	}
+	res := chirpFromDatabase(dbChirp)
+	cfg.announceChirp(req.Context(), dbChirp)
 
 	dat, err := json.Marshal(res)
 	if err != nil {
@@ -232,7 +622,13 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	// Held chirps are saved but only their author can see them until a
+	// moderator approves them.
+	if dbChirp.ModerationStatus == "held" {
+		w.WriteHeader(http.StatusAccepted)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
 	w.Write(dat)
 
 }
@@ -249,6 +645,10 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if violations := cfg.passwordPolicy.Validate(params.Password, params.Email); len(violations) > 0 {
+		respondPasswordViolations(w, violations)
+		return
+	}
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -271,6 +671,7 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, req *http.Request) {
 		Email:       user.Email,
 		IsChirpyRed: user.IsChirpyRed,
 	}
+	cfg.emitWebhook(req.Context(), webhookUserCreated, resUser)
 	dat, err := json.Marshal(resUser)
 	if err != nil {
 		fmt.Println(err)
@@ -284,6 +685,7 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, req *http.Request) {
 	var chirps []database.Chirp
 	var err error
 
+	viewerID := cfg.viewerID(req)
 	authorID := req.URL.Query().Get("author_id")
 	if authorID != "" {
 		authorUUID, err := uuid.Parse(authorID)
@@ -291,9 +693,12 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, req *http.Request) {
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		chirps, err = cfg.db.GetChirpsByUserID(req.Context(), authorUUID)
+		chirps, err = cfg.db.GetChirpsByUserID(req.Context(), database.GetChirpsByUserIDParams{
+			UserID:   authorUUID,
+			ViewerID: viewerID,
+		})
 	} else {
-		chirps, err = cfg.db.GetChirps(req.Context())
+		chirps, err = cfg.db.GetChirps(req.Context(), viewerID)
 	}
 
 	if err != nil {
@@ -308,15 +713,19 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, req *http.Request) {
 		})
 	}
 
+	preference := cfg.sensitiveContentPreference(req.Context(), viewerID)
+	expand := req.URL.Query().Get("expand") == "true"
+	pinnedOnly := req.URL.Query().Get("pinned") == "true"
 	resChirps := []Chirp{}
 	for _, chirp := range chirps {
-		resChirps = append(resChirps, Chirp{
-			ID:        chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body:      chirp.Body,
-			UserID:    chirp.UserID,
-		})
+		if pinnedOnly && !chirp.PinnedAt.Valid {
+			continue
+		}
+		resChirp, ok := presentChirp(chirp, viewerID, preference, expand)
+		if !ok {
+			continue
+		}
+		resChirps = append(resChirps, resChirp)
 	}
 
 	dat, err := json.Marshal(resChirps)
@@ -338,19 +747,23 @@ func (cfg *apiConfig) getChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
+	viewerID := cfg.viewerID(req)
+	chirp, err := cfg.db.GetChirpByID(req.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	resChirp := Chirp{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
+	// A chirp fetched by ID was asked for directly, so "omit" collapses it
+	// rather than pretending it doesn't exist.
+	preference := cfg.sensitiveContentPreference(req.Context(), viewerID)
+	if preference == sensitiveOmit {
+		preference = sensitiveCollapse
 	}
+	resChirp, _ := presentChirp(chirp, viewerID, preference, req.URL.Query().Get("expand") == "true")
 	dat, err := json.Marshal(resChirp)
 	if err != nil {
 		fmt.Println(err)
@@ -375,7 +788,6 @@ func (cfg *apiConfig) login(w http.ResponseWriter, req *http.Request) {
 		return
 
 	}
-	hour := 3600
 	user, err := cfg.db.GetUserByEmail(req.Context(), reqCred.Email)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -391,7 +803,29 @@ func (cfg *apiConfig) login(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.jwt_secret, time.Duration(hour)*time.Second)
+	cfg.respondWithLogin(w, req, user)
+}
+
+// respondWithLogin issues a fresh access and refresh token for an already
+// authenticated user. Every login method ends here so they all hand out the
+// same LoginResponse and share the same side effects.
+func (cfg *apiConfig) respondWithLogin(w http.ResponseWriter, req *http.Request, user database.User) {
+	if isSuspended(user) {
+		respondAccountSuspended(w, user)
+		return
+	}
+	// Logging back in during the grace period cancels a pending deletion.
+	if user.DeletionRequestedAt.Valid {
+		err := cfg.db.CancelUserDeletion(req.Context(), user.ID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	hour := 3600
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.jwt_secret, time.Duration(hour)*time.Second)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -450,8 +884,17 @@ func (cfg *apiConfig) refreshToken(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userId := refreshTokenDb.UserID
-	accessToken, err := auth.MakeJWT(userId, cfg.jwt_secret, time.Hour)
+	user, err := cfg.db.GetUserByID(req.Context(), refreshTokenDb.UserID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if isSuspended(user) {
+		respondAccountSuspended(w, user)
+		return
+	}
+	accessToken, err := auth.MakeJWT(user.ID, user.Role, cfg.jwt_secret, time.Hour)
 	if err != nil {
 		fmt.Println(err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -514,6 +957,10 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if violations := cfg.passwordPolicy.Validate(params.Password, params.Email); len(violations) > 0 {
+		respondPasswordViolations(w, violations)
+		return
+	}
 
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
@@ -538,6 +985,7 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, req *http.Request) {
 		Email:       user.Email,
 		IsChirpyRed: user.IsChirpyRed,
 	}
+	cfg.emitWebhook(req.Context(), webhookUserUpdated, resUser)
 	dat, err := json.Marshal(resUser)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -565,7 +1013,8 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.db.GetChirpByID(req.Context(), chirpID)
+	// Authors can still delete their own chirps after a moderator hid them.
+	chirp, err := cfg.db.GetChirpByIDAnyStatus(req.Context(), chirpID)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
@@ -581,50 +1030,6 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	cfg.announceChirpRemoved(req.Context(), chirp)
 	w.WriteHeader(http.StatusNoContent)
 }
-
-func (cfg *apiConfig) upgradeUserToChirpyRed(w http.ResponseWriter, req *http.Request) {
-
-	type polkaWebhook struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-
-	webhook := polkaWebhook{}
-	decoder := json.NewDecoder(req.Body)
-	err := decoder.Decode(&webhook)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
-	apiKey, err := auth.GetAPIKey(req.Header)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	if apiKey != cfg.polka_secret {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-	if webhook.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	userID, err := uuid.Parse(webhook.Data.UserID)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-
-	_, err = cfg.db.UpgradeUserToChirpyRed(req.Context(), userID)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		return
-	}
-	w.WriteHeader(http.StatusNoContent)
-
-}
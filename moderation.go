@@ -0,0 +1,31 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/moderation"
+)
+
+// loadModerator builds the moderators every new chirp passes through: the
+// banned-word list, then the external classifier at MODERATION_WEBHOOK_URL
+// when one is configured.
+func (cfg *apiConfig) loadModerator() moderation.Moderator {
+	chain := moderation.Chain{moderation.WordList{Filter: cfg.profanityFilter}}
+
+	url := os.Getenv("MODERATION_WEBHOOK_URL")
+	if url == "" {
+		return chain
+	}
+	webhook := moderation.Webhook{
+		URL:      url,
+		Timeout:  2 * time.Second,
+		FailOpen: true,
+	}
+	envDuration("MODERATION_WEBHOOK_TIMEOUT", &webhook.Timeout)
+	if failOpen, err := strconv.ParseBool(os.Getenv("MODERATION_WEBHOOK_FAIL_OPEN")); err == nil {
+		webhook.FailOpen = failOpen
+	}
+	return append(chain, webhook)
+}
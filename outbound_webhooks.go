@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/webhooks"
+)
+
+// Events integrators can subscribe to.
+const (
+	webhookChirpCreated   = "chirp.created"
+	webhookChirpDeleted   = "chirp.deleted"
+	webhookUserCreated    = "user.created"
+	webhookUserUpdated    = "user.updated"
+	webhookUserUpgraded   = "user.upgraded"
+	webhookUserDowngraded = "user.downgraded"
+)
+
+var outboundWebhookEvents = map[string]bool{
+	webhookChirpCreated:   true,
+	webhookChirpDeleted:   true,
+	webhookUserCreated:    true,
+	webhookUserUpdated:    true,
+	webhookUserUpgraded:   true,
+	webhookUserDowngraded: true,
+}
+
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliveryDelivered = "delivered"
+	webhookDeliveryFailed    = "failed"
+)
+
+const (
+	// maxWebhookAttempts is how many times a delivery is tried before it is
+	// marked failed and left for a manual redelivery.
+	maxWebhookAttempts     = 10
+	webhookDeliveryBatch   = 50
+	webhookDeliveryTimeout = 10 * time.Second
+	// webhookDeliveryWorkers is how many deliveries of a batch are sent at
+	// once, so a batch takes at most 50 / 10 × 10s = 50s even when every
+	// endpoint times out.
+	webhookDeliveryWorkers = 10
+	// webhookDeliveryLease is how long a claimed delivery is kept from other
+	// senders. It must be longer than a batch can take to send.
+	webhookDeliveryLease = 5 * time.Minute
+)
+
+var webhookBackoff = webhooks.Backoff{Base: 30 * time.Second, Max: 6 * time.Hour}
+
+var webhookClient = webhooks.NewClient(webhookDeliveryTimeout)
+
+type WebhookEndpoint struct {
+	ID          uuid.UUID  `json:"id"`
+	URL         string     `json:"url"`
+	Events      []string   `json:"events"`
+	Description string     `json:"description"`
+	Active      bool       `json:"active"`
+	CreatedBy   *uuid.UUID `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EndpointID     uuid.UUID       `json:"endpoint_id"`
+	EventID        uuid.UUID       `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int32          `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// emitWebhook queues an event for every active endpoint subscribed to it.
+// Failures are logged rather than returned so they never fail the request
+// that caused the event.
+func (cfg *apiConfig) emitWebhook(ctx context.Context, eventType string, data any) {
+	endpoints, err := cfg.db.GetWebhookEndpointsForEvent(ctx, eventType)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(endpoints) == 0 {
+		return
+	}
+
+	eventID := uuid.New()
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID `json:"id"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, endpoint := range endpoints {
+		_, err = cfg.db.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+			EndpointID: endpoint.ID,
+			EventID:    eventID,
+			EventType:  eventType,
+			Payload:    payload,
+		})
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Whichever replica hears this first sends the deliveries now instead
+	// of on its next poll.
+	cfg.publishEvent(ctx, topicWebhooksPending, nil)
+}
+
+// deliverWebhooks sends the deliveries that are due. Each one is claimed
+// for webhookDeliveryLease first, so several instances can run this side by
+// side and a delivery whose sender crashed is picked up again later.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) {
+	now := time.Now()
+	deliveries, err := cfg.db.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: now.Add(webhookDeliveryLease),
+		Now:        now,
+		BatchSize:  webhookDeliveryBatch,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	sender := webhooks.Sender{Timeout: webhookDeliveryTimeout, Client: webhookClient}
+	slots := make(chan struct{}, webhookDeliveryWorkers)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			cfg.deliverWebhook(ctx, sender, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliverWebhook sends one claimed delivery and records the attempt.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, sender webhooks.Sender, delivery database.WebhookDelivery) {
+	// Past the lease another sender may already have claimed it.
+	if !time.Now().Before(delivery.NextAttemptAt) {
+		return
+	}
+	endpoint, err := cfg.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Claiming set next_attempt_at to the end of the lease, so the attempt
+	// is only recorded if nobody has claimed the delivery since.
+	params := database.RecordWebhookDeliveryAttemptParams{
+		ID:            delivery.ID,
+		LeaseUntil:    delivery.NextAttemptAt,
+		Status:        webhookDeliveryDelivered,
+		NextAttemptAt: time.Now(),
+	}
+	if !endpoint.Active {
+		params.Status = webhookDeliveryFailed
+		params.LastError = sql.NullString{String: "endpoint is disabled", Valid: true}
+	} else {
+		status, err := sender.Send(ctx, webhooks.Delivery{
+			URL:       endpoint.Url,
+			Secret:    endpoint.Secret,
+			EventID:   delivery.EventID,
+			EventType: delivery.EventType,
+			Payload:   delivery.Payload,
+		}, time.Now())
+		params.ResponseStatus = sql.NullInt32{Int32: int32(status), Valid: status != 0}
+		if err != nil {
+			params.LastError = sql.NullString{String: err.Error(), Valid: true}
+			params.Status = webhookDeliveryPending
+			params.NextAttemptAt = time.Now().Add(webhookBackoff.Delay(int(delivery.Attempts) + 1))
+			if delivery.Attempts+1 >= maxWebhookAttempts {
+				params.Status = webhookDeliveryFailed
+			}
+		}
+	}
+
+	_, err = cfg.db.RecordWebhookDeliveryAttempt(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Printf("lost the lease on webhook delivery %s, not recording the attempt\n", delivery.ID)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	adminID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if msg := validateWebhookEndpoint(params.URL, params.Events); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	endpoint, err := cfg.db.CreateWebhookEndpoint(req.Context(), database.CreateWebhookEndpointParams{
+		Url:         params.URL,
+		Secret:      "whsec_" + hex.EncodeToString(secret),
+		Events:      params.Events,
+		Description: params.Description,
+		CreatedBy:   uuid.NullUUID{UUID: adminID, Valid: true},
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := webhookEndpointFromDatabase(endpoint)
+	res.Secret = endpoint.Secret
+	dat, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getWebhookEndpoints(w http.ResponseWriter, req *http.Request) {
+	rows, err := cfg.db.GetWebhookEndpoints(req.Context())
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	endpoints := []WebhookEndpoint{}
+	for _, row := range rows {
+		endpoints = append(endpoints, webhookEndpointFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(endpoints)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) updateWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		URL         *string  `json:"url"`
+		Events      []string `json:"events"`
+		Description *string  `json:"description"`
+		Active      *bool    `json:"active"`
+	}
+
+	endpoint, ok := cfg.webhookEndpointFromPath(w, req)
+	if !ok {
+		return
+	}
+	params := parameters{}
+	err := json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	update := database.UpdateWebhookEndpointParams{
+		ID:          endpoint.ID,
+		Url:         endpoint.Url,
+		Events:      endpoint.Events,
+		Description: endpoint.Description,
+		Active:      endpoint.Active,
+	}
+	if params.URL != nil {
+		update.Url = *params.URL
+	}
+	if params.Events != nil {
+		update.Events = params.Events
+	}
+	if params.Description != nil {
+		update.Description = *params.Description
+	}
+	if params.Active != nil {
+		update.Active = *params.Active
+	}
+	if msg := validateWebhookEndpoint(update.Url, update.Events); msg != "" {
+		respondWithError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	endpoint, err = cfg.db.UpdateWebhookEndpoint(req.Context(), update)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dat, err := json.Marshal(webhookEndpointFromDatabase(endpoint))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, req *http.Request) {
+	endpointID, err := uuid.Parse(req.PathValue("endpointID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	deleted, err := cfg.db.DeleteWebhookEndpoint(req.Context(), endpointID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getWebhookDeliveries(w http.ResponseWriter, req *http.Request) {
+	endpoint, ok := cfg.webhookEndpointFromPath(w, req)
+	if !ok {
+		return
+	}
+	status := req.URL.Query().Get("status")
+	if status != "" && status != webhookDeliveryPending && status != webhookDeliveryDelivered && status != webhookDeliveryFailed {
+		respondWithError(w, http.StatusBadRequest, "Unknown delivery status")
+		return
+	}
+	limit := 100
+	if s := req.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := cfg.db.GetWebhookDeliveriesByEndpoint(req.Context(), database.GetWebhookDeliveriesByEndpointParams{
+		EndpointID:    endpoint.ID,
+		Status:        sql.NullString{String: status, Valid: status != ""},
+		MaxDeliveries: int32(limit),
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	deliveries := []WebhookDelivery{}
+	for _, row := range rows {
+		deliveries = append(deliveries, webhookDeliveryFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(deliveries)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// redeliverWebhook queues a fresh copy of a delivery. The original stays in
+// the log as it was; the copy keeps its event ID so receivers that already
+// handled it can tell.
+func (cfg *apiConfig) redeliverWebhook(w http.ResponseWriter, req *http.Request) {
+	deliveryID, err := uuid.Parse(req.PathValue("deliveryID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	delivery, err := cfg.db.GetWebhookDelivery(req.Context(), deliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	delivery, err = cfg.db.CreateWebhookDelivery(req.Context(), database.CreateWebhookDeliveryParams{
+		EndpointID: delivery.EndpointID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.publishEvent(req.Context(), topicWebhooksPending, nil)
+
+	dat, err := json.Marshal(webhookDeliveryFromDatabase(delivery))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) webhookEndpointFromPath(w http.ResponseWriter, req *http.Request) (database.WebhookEndpoint, bool) {
+	endpointID, err := uuid.Parse(req.PathValue("endpointID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return database.WebhookEndpoint{}, false
+	}
+	endpoint, err := cfg.db.GetWebhookEndpoint(req.Context(), endpointID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return database.WebhookEndpoint{}, false
+	}
+	return endpoint, true
+}
+
+// validateWebhookEndpoint returns why an endpoint can't be saved, or "".
+func validateWebhookEndpoint(rawURL string, events []string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "URL must be an absolute http or https URL"
+	}
+	if len(events) == 0 {
+		return "At least one event is required"
+	}
+	for _, event := range events {
+		if !outboundWebhookEvents[event] {
+			return fmt.Sprintf("Unknown event %q", event)
+		}
+	}
+	return ""
+}
+
+func webhookEndpointFromDatabase(endpoint database.WebhookEndpoint) WebhookEndpoint {
+	res := WebhookEndpoint{
+		ID:          endpoint.ID,
+		URL:         endpoint.Url,
+		Events:      endpoint.Events,
+		Description: endpoint.Description,
+		Active:      endpoint.Active,
+		CreatedAt:   endpoint.CreatedAt,
+		UpdatedAt:   endpoint.UpdatedAt,
+	}
+	if endpoint.CreatedBy.Valid {
+		res.CreatedBy = &endpoint.CreatedBy.UUID
+	}
+	return res
+}
+
+func webhookDeliveryFromDatabase(delivery database.WebhookDelivery) WebhookDelivery {
+	res := WebhookDelivery{
+		ID:         delivery.ID,
+		EndpointID: delivery.EndpointID,
+		EventID:    delivery.EventID,
+		EventType:  delivery.EventType,
+		Payload:    delivery.Payload,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		CreatedAt:  delivery.CreatedAt,
+	}
+	if delivery.Status == webhookDeliveryPending {
+		res.NextAttemptAt = &delivery.NextAttemptAt
+	}
+	if delivery.LastAttemptAt.Valid {
+		res.LastAttemptAt = &delivery.LastAttemptAt.Time
+	}
+	if delivery.ResponseStatus.Valid {
+		res.ResponseStatus = &delivery.ResponseStatus.Int32
+	}
+	if delivery.LastError.Valid {
+		res.LastError = &delivery.LastError.String
+	}
+	if delivery.DeliveredAt.Valid {
+		res.DeliveredAt = &delivery.DeliveredAt.Time
+	}
+	return res
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/events"
+	"github.com/ifeanyibatman/chirpy/internal/webhooks"
+)
+
+func TestDeliverWebhooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		attempts int32
+		// leaseLost makes recording the attempt find that another sender
+		// has claimed the delivery since.
+		leaseLost  bool
+		wantStatus string
+	}{
+		{name: "delivered", status: http.StatusOK, wantStatus: webhookDeliveryDelivered},
+		{name: "failed attempt is retried", status: http.StatusInternalServerError, wantStatus: webhookDeliveryPending},
+		{name: "redirect is a failed attempt", status: http.StatusFound, wantStatus: webhookDeliveryPending},
+		{name: "last attempt fails the delivery", status: http.StatusInternalServerError, attempts: maxWebhookAttempts - 1, wantStatus: webhookDeliveryFailed},
+		{name: "lease lost", status: http.StatusOK, leaseLost: true, wantStatus: webhookDeliveryDelivered},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var sentIDs []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				sentIDs = append(sentIDs, r.Header.Get(webhooks.EventIDHeader))
+				mu.Unlock()
+				if tc.status == http.StatusFound {
+					w.Header().Set("Location", "/elsewhere")
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			fake, queries := newFakeDB(t)
+			cfg := &apiConfig{db: queries}
+			endpoint := database.WebhookEndpoint{ID: uuid.New(), Url: srv.URL, Secret: "secret", Events: []string{webhookChirpCreated}, Active: true}
+			delivery := database.WebhookDelivery{
+				ID:         uuid.New(),
+				EndpointID: endpoint.ID,
+				EventID:    uuid.New(),
+				EventType:  webhookChirpCreated,
+				Payload:    []byte(`{}`),
+				Status:     webhookDeliveryPending,
+				Attempts:   tc.attempts,
+			}
+
+			var leaseUntil time.Time
+			fake.handle("ClaimDueWebhookDeliveries", func(args []driver.Value) ([][]driver.Value, int64, error) {
+				leaseUntil = args[0].(time.Time)
+				if lease := leaseUntil.Sub(args[1].(time.Time)); lease != webhookDeliveryLease {
+					t.Errorf("claimed for %v, want %v", lease, webhookDeliveryLease)
+				}
+				claimed := delivery
+				claimed.NextAttemptAt = leaseUntil
+				return [][]driver.Value{fakeRow(claimed)}, 0, nil
+			})
+			fake.handle("GetWebhookEndpoint", func(args []driver.Value) ([][]driver.Value, int64, error) {
+				return [][]driver.Value{fakeRow(endpoint)}, 0, nil
+			})
+			var recorded []driver.Value
+			fake.handle("RecordWebhookDeliveryAttempt", func(args []driver.Value) ([][]driver.Value, int64, error) {
+				recorded = args
+				if tc.leaseLost {
+					return nil, 0, nil
+				}
+				return [][]driver.Value{fakeRow(delivery)}, 0, nil
+			})
+
+			cfg.deliverWebhooks(context.Background())
+
+			if len(sentIDs) != 1 || sentIDs[0] != delivery.EventID.String() {
+				t.Fatalf("sent event IDs %v, want [%s]", sentIDs, delivery.EventID)
+			}
+			if recorded == nil {
+				t.Fatal("the attempt wasn't recorded")
+			}
+			if status := recorded[0].(string); status != tc.wantStatus {
+				t.Errorf("recorded status %q, want %q", status, tc.wantStatus)
+			}
+			if lease := recorded[5].(time.Time); !lease.Equal(leaseUntil) {
+				t.Errorf("recorded against lease %v, want %v", lease, leaseUntil)
+			}
+			retryAt := recorded[1].(time.Time)
+			if tc.wantStatus == webhookDeliveryPending && retryAt.Before(time.Now().Add(webhookBackoff.Base/2)) {
+				t.Errorf("retry at %v, want one backed off by about %v", retryAt, webhookBackoff.Base)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhookAfterLeaseExpired(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a delivery was sent after its lease expired")
+	}))
+	defer srv.Close()
+
+	// No queries are registered, so recording an attempt fails the test.
+	_, queries := newFakeDB(t)
+	cfg := &apiConfig{db: queries}
+	cfg.deliverWebhook(context.Background(), webhooks.Sender{}, database.WebhookDelivery{
+		ID:            uuid.New(),
+		EventID:       uuid.New(),
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+}
+
+func TestRedeliverWebhookKeepsEventID(t *testing.T) {
+	fake, queries := newFakeDB(t)
+	cfg := &apiConfig{db: queries, events: events.NewMemory()}
+	original := database.WebhookDelivery{
+		ID:         uuid.New(),
+		EndpointID: uuid.New(),
+		EventID:    uuid.New(),
+		EventType:  webhookChirpCreated,
+		Payload:    []byte(`{}`),
+		Status:     webhookDeliveryFailed,
+		Attempts:   maxWebhookAttempts,
+	}
+	fake.handle("GetWebhookDelivery", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		return [][]driver.Value{fakeRow(original)}, 0, nil
+	})
+	var created []driver.Value
+	fake.handle("CreateWebhookDelivery", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		created = args
+		queued := original
+		queued.ID = uuid.New()
+		queued.Status = webhookDeliveryPending
+		queued.Attempts = 0
+		return [][]driver.Value{fakeRow(queued)}, 0, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/webhooks/deliveries/"+original.ID.String()+"/redeliver", nil)
+	req.SetPathValue("deliveryID", original.ID.String())
+	w := httptest.NewRecorder()
+	cfg.redeliverWebhook(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d, want %d", w.Code, http.StatusAccepted)
+	}
+	if created == nil {
+		t.Fatal("no delivery was queued")
+	}
+	if eventID := created[1].(string); eventID != original.EventID.String() {
+		t.Errorf("queued event ID %s, want %s", eventID, original.EventID)
+	}
+}
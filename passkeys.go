@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonyTimeout      = 5 * time.Minute
+)
+
+type Passkey struct {
+	ID         string     `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	SignCount  int64      `json:"sign_count"`
+}
+
+// passkeyUser adapts a database user to the webauthn.User interface.
+type passkeyUser struct {
+	user        database.User
+	credentials []webauthn.Credential
+}
+
+func (u passkeyUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func loadWebAuthn() (*webauthn.WebAuthn, error) {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	origins := []string{"http://localhost:8080"}
+	if env := os.Getenv("WEBAUTHN_RP_ORIGINS"); env != "" {
+		origins = strings.Split(env, ",")
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: "Chirpy",
+		RPOrigins:     origins,
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyTimeout},
+			Registration: webauthn.TimeoutConfig{Enforce: true, Timeout: ceremonyTimeout},
+		},
+	})
+}
+
+func (cfg *apiConfig) loadPasskeyUser(ctx context.Context, user database.User) (passkeyUser, error) {
+	rows, err := cfg.db.GetPasskeyCredentialsByUserID(ctx, user.ID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+	pu := passkeyUser{user: user}
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(row.Credential, &credential); err != nil {
+			return passkeyUser{}, err
+		}
+		// The column is the source of truth for the counter, the JSON copy
+		// only records what the authenticator reported at registration.
+		credential.Authenticator.SignCount = uint32(row.SignCount)
+		pu.credentials = append(pu.credentials, credential)
+	}
+	return pu, nil
+}
+
+func (cfg *apiConfig) saveWebAuthnSession(ctx context.Context, userID uuid.NullUUID, ceremony string, session *webauthn.SessionData) (uuid.UUID, error) {
+	dat, err := json.Marshal(session)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return cfg.db.CreateWebauthnSession(ctx, database.CreateWebauthnSessionParams{
+		UserID:      userID,
+		Ceremony:    ceremony,
+		SessionData: dat,
+		ExpiresAt:   time.Now().Add(ceremonyTimeout),
+	})
+}
+
+// consumeWebAuthnSession looks up and deletes the session named by the
+// session_id query parameter so every challenge can only be answered once.
+func (cfg *apiConfig) consumeWebAuthnSession(req *http.Request, ceremony string) (database.WebauthnSession, webauthn.SessionData, error) {
+	var session webauthn.SessionData
+	sessionID, err := uuid.Parse(req.URL.Query().Get("session_id"))
+	if err != nil {
+		return database.WebauthnSession{}, session, err
+	}
+	row, err := cfg.db.ConsumeWebauthnSession(req.Context(), database.ConsumeWebauthnSessionParams{
+		ID:       sessionID,
+		Ceremony: ceremony,
+	})
+	if err != nil {
+		return database.WebauthnSession{}, session, err
+	}
+	if row.ExpiresAt.Before(time.Now()) {
+		return database.WebauthnSession{}, session, fmt.Errorf("webauthn session %s has expired", sessionID)
+	}
+	err = json.Unmarshal(row.SessionData, &session)
+	return row, session, err
+}
+
+// deleteExpiredWebAuthnSessions removes ceremonies that were started but
+// never finished.
+func (cfg *apiConfig) deleteExpiredWebAuthnSessions(ctx context.Context) {
+	deleted, err := cfg.db.DeleteExpiredWebauthnSessions(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if deleted > 0 {
+		fmt.Printf("deleted %d expired passkey sessions\n", deleted)
+	}
+}
+
+func (cfg *apiConfig) beginPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		SessionID uuid.UUID `json:"session_id"`
+		protocol.CredentialCreation
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	pu, err := cfg.loadPasskeyUser(req.Context(), user)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	exclusions := []protocol.CredentialDescriptor{}
+	for _, credential := range pu.credentials {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	creation, session, err := cfg.webAuthn.BeginRegistration(pu,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sessionID, err := cfg.saveWebAuthnSession(req.Context(), uuid.NullUUID{UUID: user.ID, Valid: true}, ceremonyRegistration, session)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(response{
+		SessionID:          sessionID,
+		CredentialCreation: *creation,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) finishPasskeyRegistration(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	row, session, err := cfg.consumeWebAuthnSession(req, ceremonyRegistration)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Unknown or expired registration session")
+		return
+	}
+	if row.UserID.UUID != userID {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	pu, err := cfg.loadPasskeyUser(req.Context(), user)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBody(req.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid attestation response")
+		return
+	}
+	credential, err := cfg.webAuthn.CreateCredential(pu, session, parsed)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Passkey registration failed: %s", err))
+		return
+	}
+
+	dat, err := json.Marshal(credential)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	stored, err := cfg.db.CreatePasskeyCredential(req.Context(), database.CreatePasskeyCredentialParams{
+		ID:         credential.ID,
+		UserID:     user.ID,
+		Credential: dat,
+		SignCount:  int64(credential.Authenticator.SignCount),
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+
+	dat, err = json.Marshal(passkeyFromDatabase(stored))
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getPasskeys(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	rows, err := cfg.db.GetPasskeyCredentialsByUserID(req.Context(), userID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	passkeys := []Passkey{}
+	for _, row := range rows {
+		passkeys = append(passkeys, passkeyFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(passkeys)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) deletePasskey(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	credentialID, err := base64.RawURLEncoding.DecodeString(req.PathValue("credentialID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := cfg.db.DeletePasskeyCredential(req.Context(), database.DeletePasskeyCredentialParams{
+		ID:     credentialID,
+		UserID: userID,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) beginPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+	type response struct {
+		SessionID uuid.UUID `json:"session_id"`
+		protocol.CredentialAssertion
+	}
+
+	// The body is optional: without an email the browser is asked for any
+	// discoverable passkey registered for this site.
+	params := parameters{}
+	if req.ContentLength != 0 {
+		decoder := json.NewDecoder(req.Body)
+		if err := decoder.Decode(&params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	var assertion *protocol.CredentialAssertion
+	var session *webauthn.SessionData
+	userID := uuid.NullUUID{}
+	if params.Email != "" {
+		user, err := cfg.db.GetUserByEmail(req.Context(), params.Email)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		pu, err := cfg.loadPasskeyUser(req.Context(), user)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if len(pu.credentials) == 0 {
+			respondWithError(w, http.StatusNotFound, "No passkeys registered for this account")
+			return
+		}
+		assertion, session, err = cfg.webAuthn.BeginLogin(pu)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		userID = uuid.NullUUID{UUID: user.ID, Valid: true}
+	} else {
+		var err error
+		assertion, session, err = cfg.webAuthn.BeginDiscoverableLogin()
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	sessionID, err := cfg.saveWebAuthnSession(req.Context(), userID, ceremonyLogin, session)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dat, err := json.Marshal(response{
+		SessionID:           sessionID,
+		CredentialAssertion: *assertion,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) finishPasskeyLogin(w http.ResponseWriter, req *http.Request) {
+	row, session, err := cfg.consumeWebAuthnSession(req, ceremonyLogin)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Unknown or expired login session")
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBody(req.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid assertion response")
+		return
+	}
+
+	var pu passkeyUser
+	var credential *webauthn.Credential
+	if row.UserID.Valid {
+		user, err := cfg.db.GetUserByID(req.Context(), row.UserID.UUID)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		pu, err = cfg.loadPasskeyUser(req.Context(), user)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		credential, err = cfg.webAuthn.ValidateLogin(pu, session, parsed)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	} else {
+		credential, err = cfg.webAuthn.ValidateDiscoverableLogin(func(rawID, userHandle []byte) (webauthn.User, error) {
+			userID, err := uuid.FromBytes(userHandle)
+			if err != nil {
+				return nil, err
+			}
+			user, err := cfg.db.GetUserByID(req.Context(), userID)
+			if err != nil {
+				return nil, err
+			}
+			pu, err = cfg.loadPasskeyUser(req.Context(), user)
+			return pu, err
+		}, session, parsed)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// A counter that did not move forward means two copies of the private
+	// key may exist, so the assertion is refused rather than just logged.
+	if credential.Authenticator.CloneWarning {
+		respondWithError(w, http.StatusUnauthorized, "Passkey sign counter did not increase; the authenticator may have been cloned")
+		return
+	}
+	dat, err := json.Marshal(credential)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	updated, err := cfg.db.UpdatePasskeySignCount(req.Context(), database.UpdatePasskeySignCountParams{
+		SignCount:  int64(credential.Authenticator.SignCount),
+		Credential: dat,
+		ID:         credential.ID,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, http.StatusUnauthorized, "Passkey sign counter did not increase; the authenticator may have been cloned")
+		return
+	}
+
+	cfg.respondWithLogin(w, req, pu.user)
+}
+
+func passkeyFromDatabase(row database.PasskeyCredential) Passkey {
+	passkey := Passkey{
+		ID:        base64.RawURLEncoding.EncodeToString(row.ID),
+		CreatedAt: row.CreatedAt,
+		SignCount: row.SignCount,
+	}
+	if row.LastUsedAt.Valid {
+		passkey.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return passkey
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/webauthntest"
+)
+
+const passkeyTestOrigin = "http://localhost:8080"
+
+// passkeyStore holds the rows the passkey handlers read and write.
+type passkeyStore struct {
+	users       map[uuid.UUID]database.User
+	sessions    map[uuid.UUID]database.WebauthnSession
+	credentials []database.PasskeyCredential
+}
+
+func newPasskeyTestConfig(t *testing.T) (*apiConfig, *passkeyStore) {
+	t.Setenv("WEBAUTHN_RP_ID", "")
+	t.Setenv("WEBAUTHN_RP_ORIGINS", "")
+	webAuthn, err := loadWebAuthn()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake, queries := newFakeDB(t)
+	cfg := &apiConfig{db: queries, jwt_secret: "passkey-test-secret", webAuthn: webAuthn}
+	store := &passkeyStore{
+		users:    map[uuid.UUID]database.User{},
+		sessions: map[uuid.UUID]database.WebauthnSession{},
+	}
+
+	fake.handle("GetUserByID", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		user, ok := store.users[uuid.MustParse(args[0].(string))]
+		if !ok {
+			return nil, 0, nil
+		}
+		return [][]driver.Value{fakeRow(user)}, 0, nil
+	})
+	fake.handle("GetUserByEmail", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		for _, user := range store.users {
+			if user.Email == args[0].(string) {
+				return [][]driver.Value{fakeRow(user)}, 0, nil
+			}
+		}
+		return nil, 0, nil
+	})
+	fake.handle("GetPasskeyCredentialsByUserID", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		rows := [][]driver.Value{}
+		for _, credential := range store.credentials {
+			if credential.UserID.String() == args[0].(string) {
+				rows = append(rows, fakeRow(credential))
+			}
+		}
+		return rows, 0, nil
+	})
+	fake.handle("CreatePasskeyCredential", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		credential := database.PasskeyCredential{
+			ID:         args[0].([]byte),
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+			UserID:     uuid.MustParse(args[1].(string)),
+			Credential: args[2].([]byte),
+			SignCount:  args[3].(int64),
+		}
+		store.credentials = append(store.credentials, credential)
+		return [][]driver.Value{fakeRow(credential)}, 0, nil
+	})
+	fake.handle("UpdatePasskeySignCount", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		signCount := args[0].(int64)
+		for i, credential := range store.credentials {
+			if bytes.Equal(credential.ID, args[2].([]byte)) && (credential.SignCount < signCount || signCount == 0) {
+				store.credentials[i].SignCount = signCount
+				store.credentials[i].Credential = args[1].([]byte)
+				return nil, 1, nil
+			}
+		}
+		return nil, 0, nil
+	})
+	fake.handle("CreateWebauthnSession", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		session := database.WebauthnSession{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			Ceremony:    args[1].(string),
+			SessionData: args[2].([]byte),
+			ExpiresAt:   args[3].(time.Time),
+		}
+		if args[0] != nil {
+			session.UserID = uuid.NullUUID{UUID: uuid.MustParse(args[0].(string)), Valid: true}
+		}
+		store.sessions[session.ID] = session
+		return [][]driver.Value{{session.ID.String()}}, 0, nil
+	})
+	fake.handle("ConsumeWebauthnSession", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		id := uuid.MustParse(args[0].(string))
+		session, ok := store.sessions[id]
+		if !ok || session.Ceremony != args[1].(string) {
+			return nil, 0, nil
+		}
+		delete(store.sessions, id)
+		return [][]driver.Value{fakeRow(session)}, 0, nil
+	})
+	fake.handle("CreateRefreshToken", func(args []driver.Value) ([][]driver.Value, int64, error) {
+		return nil, 1, nil
+	})
+	return cfg, store
+}
+
+func (s *passkeyStore) addUser(email string) database.User {
+	user := database.User{
+		ID:               uuid.New(),
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		Email:            email,
+		Role:             auth.RoleUser,
+		SensitiveContent: sensitiveCollapse,
+	}
+	s.users[user.ID] = user
+	return user
+}
+
+// ceremony is the body of a begin endpoint: a session ID alongside the
+// options for the authenticator.
+type ceremony struct {
+	SessionID uuid.UUID
+	Options   []byte
+}
+
+func callPasskeyHandler(t *testing.T, handler http.HandlerFunc, target, token string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func beginCeremony(t *testing.T, w *httptest.ResponseRecorder) ceremony {
+	t.Helper()
+	if w.Code != http.StatusOK {
+		t.Fatalf("begin: got status %d: %s", w.Code, w.Body)
+	}
+	var res struct {
+		SessionID uuid.UUID `json:"session_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	return ceremony{SessionID: res.SessionID, Options: w.Body.Bytes()}
+}
+
+func registerPasskey(t *testing.T, cfg *apiConfig, user database.User, authenticator *webauthntest.Authenticator) {
+	t.Helper()
+	token, err := auth.MakeJWT(user.ID, user.Role, cfg.jwt_secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin := beginCeremony(t, callPasskeyHandler(t, cfg.beginPasskeyRegistration, "/api/passkeys/register/begin", token, nil))
+	attestation, err := authenticator.Register(begin.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := callPasskeyHandler(t, cfg.finishPasskeyRegistration, "/api/passkeys/register/finish?session_id="+begin.SessionID.String(), token, attestation)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("finish registration: got status %d: %s", w.Code, w.Body)
+	}
+}
+
+func beginLogin(t *testing.T, cfg *apiConfig, email string) ceremony {
+	t.Helper()
+	body, err := json.Marshal(map[string]string{"email": email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return beginCeremony(t, callPasskeyHandler(t, cfg.beginPasskeyLogin, "/api/passkeys/login/begin", "", body))
+}
+
+func finishLogin(t *testing.T, cfg *apiConfig, sessionID uuid.UUID, assertion []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	return callPasskeyHandler(t, cfg.finishPasskeyLogin, "/api/passkeys/login/finish?session_id="+sessionID.String(), "", assertion)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	cfg, store := newPasskeyTestConfig(t)
+	user := store.addUser("alice@example.com")
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, cfg, user, authenticator)
+
+	if len(store.credentials) != 1 || store.credentials[0].UserID != user.ID {
+		t.Fatalf("expected one credential for %s, got %+v", user.ID, store.credentials)
+	}
+
+	for _, email := range []string{"alice@example.com", ""} {
+		var begin ceremony
+		if email == "" {
+			// Discoverable login: no email, the passkey names the user.
+			begin = beginCeremony(t, callPasskeyHandler(t, cfg.beginPasskeyLogin, "/api/passkeys/login/begin", "", nil))
+		} else {
+			begin = beginLogin(t, cfg, email)
+		}
+		assertion, err := authenticator.Login(begin.Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := finishLogin(t, cfg, begin.SessionID, assertion)
+		if w.Code != http.StatusOK {
+			t.Fatalf("login with email %q: got status %d: %s", email, w.Code, w.Body)
+		}
+		var res LoginResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.ID != user.ID || res.Token == "" || res.RefreshToken == "" {
+			t.Fatalf("login with email %q: unexpected response %+v", email, res)
+		}
+	}
+	if store.credentials[0].SignCount != 2 {
+		t.Fatalf("expected sign count 2 after two logins, got %d", store.credentials[0].SignCount)
+	}
+}
+
+func TestPasskeyReplayedChallengeIsRejected(t *testing.T) {
+	cfg, store := newPasskeyTestConfig(t)
+	user := store.addUser("alice@example.com")
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, cfg, user, authenticator)
+
+	begin := beginLogin(t, cfg, user.Email)
+	assertion, err := authenticator.Login(begin.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := finishLogin(t, cfg, begin.SessionID, assertion); w.Code != http.StatusOK {
+		t.Fatalf("first login: got status %d: %s", w.Code, w.Body)
+	}
+	if w := finishLogin(t, cfg, begin.SessionID, assertion); w.Code != http.StatusBadRequest {
+		t.Fatalf("replayed login: expected %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body)
+	}
+
+	// A fresh assertion for the used challenge is refused too.
+	again, err := authenticator.Login(begin.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := finishLogin(t, cfg, begin.SessionID, again); w.Code != http.StatusBadRequest {
+		t.Fatalf("reused challenge: expected %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body)
+	}
+}
+
+func TestPasskeySignCountGoingBackwardsIsRejected(t *testing.T) {
+	cfg, store := newPasskeyTestConfig(t)
+	user := store.addUser("alice@example.com")
+	authenticator := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, cfg, user, authenticator)
+	clone := authenticator.Clone()
+
+	for i := 0; i < 2; i++ {
+		begin := beginLogin(t, cfg, user.Email)
+		assertion, err := authenticator.Login(begin.Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w := finishLogin(t, cfg, begin.SessionID, assertion); w.Code != http.StatusOK {
+			t.Fatalf("login %d: got status %d: %s", i+1, w.Code, w.Body)
+		}
+	}
+
+	// The clone still has the counter from before those logins.
+	begin := beginLogin(t, cfg, user.Email)
+	assertion, err := clone.Login(begin.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := finishLogin(t, cfg, begin.SessionID, assertion); w.Code != http.StatusUnauthorized {
+		t.Fatalf("cloned authenticator: expected %d, got %d: %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+	if store.credentials[0].SignCount != 2 {
+		t.Fatalf("sign count should stay at 2, got %d", store.credentials[0].SignCount)
+	}
+}
+
+func TestPasskeyOfAnotherUserIsRejected(t *testing.T) {
+	cfg, store := newPasskeyTestConfig(t)
+	alice := store.addUser("alice@example.com")
+	bob := store.addUser("bob@example.com")
+	aliceKey := webauthntest.New(passkeyTestOrigin)
+	bobKey := webauthntest.New(passkeyTestOrigin)
+	registerPasskey(t, cfg, alice, aliceKey)
+	registerPasskey(t, cfg, bob, bobKey)
+
+	// Start a login as Bob but answer it with Alice's passkey. Dropping the
+	// allowed credentials makes the authenticator use the one it has.
+	begin := beginLogin(t, cfg, bob.Email)
+	var options struct {
+		PublicKey map[string]any `json:"publicKey"`
+	}
+	if err := json.Unmarshal(begin.Options, &options); err != nil {
+		t.Fatal(err)
+	}
+	delete(options.PublicKey, "allowCredentials")
+	stripped, err := json.Marshal(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := aliceKey.Login(stripped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := finishLogin(t, cfg, begin.SessionID, assertion); w.Code != http.StatusUnauthorized {
+		t.Fatalf("login as bob with alice's passkey: expected %d, got %d: %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+
+	// A registration ceremony started by Bob can't be finished by Alice.
+	bobToken, err := auth.MakeJWT(bob.ID, bob.Role, cfg.jwt_secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliceToken, err := auth.MakeJWT(alice.ID, alice.Role, cfg.jwt_secret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register := beginCeremony(t, callPasskeyHandler(t, cfg.beginPasskeyRegistration, "/api/passkeys/register/begin", bobToken, nil))
+	attestation, err := aliceKey.Register(register.Options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := callPasskeyHandler(t, cfg.finishPasskeyRegistration, "/api/passkeys/register/finish?session_id="+register.SessionID.String(), aliceToken, attestation)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("finishing bob's registration as alice: expected %d, got %d: %s", http.StatusForbidden, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+)
+
+const maxGrantDays = 3650
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{4,32}$`)
+
+var (
+	// errPromoCodeRedeemed means the user has already redeemed the code.
+	errPromoCodeRedeemed = errors.New("promo code has already been redeemed")
+	// errPromoCodeUsedUp means the code has expired or reached its limit.
+	errPromoCodeUsedUp = errors.New("promo code has expired or been used up")
+)
+
+type PromoCode struct {
+	ID             uuid.UUID  `json:"id"`
+	Code           string     `json:"code"`
+	Plan           string     `json:"plan"`
+	DurationDays   int32      `json:"duration_days"`
+	MaxRedemptions *int32     `json:"max_redemptions"`
+	Redemptions    int32      `json:"redemptions"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	CreatedBy      *uuid.UUID `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type PromoRedemption struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Email      string    `json:"email"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+type Gift struct {
+	ID           uuid.UUID  `json:"id"`
+	RecipientID  uuid.UUID  `json:"recipient_id"`
+	SenderID     *uuid.UUID `json:"sender_id"`
+	Plan         string     `json:"plan"`
+	DurationDays int32      `json:"duration_days"`
+	Message      string     `json:"message"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// normalizePromoCode makes codes case-insensitive.
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// grantablePlan returns the plan to grant, defaulting to Chirpy Red, or ""
+// if it isn't a paid plan we know.
+func (cfg *apiConfig) grantablePlan(plan string) string {
+	if plan == "" {
+		plan = entitlements.PlanChirpyRed
+	}
+	if _, ok := cfg.plans[plan]; !ok || plan == entitlements.PlanFree {
+		return ""
+	}
+	return plan
+}
+
+func (cfg *apiConfig) redeemPromoCode(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	promo, err := cfg.db.GetPromoCodeByCode(req.Context(), normalizePromoCode(params.Code))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unknown promo code")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// Recording the redemption, counting the use and granting the time
+	// happen together, so a failure part way leaves the code unused. The
+	// redemption is recorded first, so a user can't use one code twice.
+	var subscription database.Subscription
+	var wasChirpyRed bool
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		// Locked up front: the redemption's foreign key takes a weaker
+		// lock on the user that granting the time would otherwise have to
+		// upgrade, deadlocking concurrent redemptions.
+		if err := q.LockUser(req.Context(), userID); err != nil {
+			return err
+		}
+		redemption, err := q.CreatePromoRedemption(req.Context(), database.CreatePromoRedemptionParams{
+			PromoCodeID: promo.ID,
+			UserID:      userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errPromoCodeRedeemed
+		}
+		if err != nil {
+			return err
+		}
+		_, err = q.ClaimPromoCode(req.Context(), promo.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errPromoCodeUsedUp
+		}
+		if err != nil {
+			return err
+		}
+		subscription, wasChirpyRed, err = writeSubscriptionChange(req.Context(), q, userID,
+			grantedSubscription(promo.Plan, promo.DurationDays, subscriptionEventPromoRedeemed, subscriptionSourcePromo, redemption.ID.String()))
+		return err
+	})
+	if errors.Is(err, errPromoCodeRedeemed) {
+		respondWithError(w, http.StatusConflict, "You have already redeemed this promo code")
+		return
+	}
+	if errors.Is(err, errPromoCodeUsedUp) {
+		respondWithError(w, http.StatusGone, "This promo code has expired or been used up")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.emitChirpyRedWebhook(req.Context(), wasChirpyRed, subscription)
+
+	dat, err := json.Marshal(subscriptionFromDatabase(subscription))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) createPromoCode(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Code           string     `json:"code"`
+		Plan           string     `json:"plan"`
+		DurationDays   int32      `json:"duration_days"`
+		MaxRedemptions *int32     `json:"max_redemptions"`
+		ExpiresAt      *time.Time `json:"expires_at"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	adminID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code := normalizePromoCode(params.Code)
+	if code == "" {
+		random := make([]byte, 5)
+		rand.Read(random)
+		code = base32.StdEncoding.EncodeToString(random)
+	}
+	if !promoCodePattern.MatchString(code) {
+		respondWithError(w, http.StatusBadRequest, "Promo codes are 4 to 32 letters, digits, dashes or underscores")
+		return
+	}
+	plan := cfg.grantablePlan(params.Plan)
+	if plan == "" {
+		respondWithError(w, http.StatusBadRequest, "Unknown plan")
+		return
+	}
+	if params.DurationDays < 1 || params.DurationDays > maxGrantDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("duration_days must be between 1 and %d", maxGrantDays))
+		return
+	}
+	if params.MaxRedemptions != nil && *params.MaxRedemptions < 1 {
+		respondWithError(w, http.StatusBadRequest, "max_redemptions must be at least 1")
+		return
+	}
+
+	_, err = cfg.db.GetPromoCodeByCode(req.Context(), code)
+	if err == nil {
+		respondWithError(w, http.StatusConflict, "A promo code with that code already exists")
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	createParams := database.CreatePromoCodeParams{
+		Code:         code,
+		Plan:         plan,
+		DurationDays: params.DurationDays,
+		CreatedBy:    uuid.NullUUID{UUID: adminID, Valid: true},
+	}
+	if params.MaxRedemptions != nil {
+		createParams.MaxRedemptions = sql.NullInt32{Int32: *params.MaxRedemptions, Valid: true}
+	}
+	if params.ExpiresAt != nil {
+		createParams.ExpiresAt = sql.NullTime{Time: *params.ExpiresAt, Valid: true}
+	}
+	promo, err := cfg.db.CreatePromoCode(req.Context(), createParams)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(promoCodeFromDatabase(promo))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getPromoCodes(w http.ResponseWriter, req *http.Request) {
+	rows, err := cfg.db.GetPromoCodes(req.Context())
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	codes := []PromoCode{}
+	for _, row := range rows {
+		codes = append(codes, promoCodeFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(codes)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getPromoRedemptions(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		PromoCode
+		RedeemedBy []PromoRedemption `json:"redeemed_by"`
+	}
+
+	codeID, err := uuid.Parse(req.PathValue("codeID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	promo, err := cfg.db.GetPromoCode(req.Context(), codeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rows, err := cfg.db.GetPromoRedemptionsByCode(req.Context(), promo.ID)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res := response{PromoCode: promoCodeFromDatabase(promo), RedeemedBy: []PromoRedemption{}}
+	for _, row := range rows {
+		res.RedeemedBy = append(res.RedeemedBy, PromoRedemption{
+			ID:         row.ID,
+			UserID:     row.UserID,
+			Email:      row.Email,
+			RedeemedAt: row.RedeemedAt,
+		})
+	}
+	dat, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) giftSubscription(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Plan         string `json:"plan"`
+		DurationDays int32  `json:"duration_days"`
+		Message      string `json:"message"`
+	}
+	type response struct {
+		Gift         Gift         `json:"gift"`
+		Subscription Subscription `json:"subscription"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	adminID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	recipientID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	params := parameters{}
+	err = json.NewDecoder(req.Body).Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	plan := cfg.grantablePlan(params.Plan)
+	if plan == "" {
+		respondWithError(w, http.StatusBadRequest, "Unknown plan")
+		return
+	}
+	if params.DurationDays < 1 || params.DurationDays > maxGrantDays {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("duration_days must be between 1 and %d", maxGrantDays))
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(req.Context(), recipientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// The gift and the time it grants are written together, so a gift is
+	// never recorded without the recipient getting it.
+	var gift database.Gift
+	var subscription database.Subscription
+	var wasChirpyRed bool
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		// Locked up front for the same reason as in redeemPromoCode.
+		if err := q.LockUser(req.Context(), recipientID); err != nil {
+			return err
+		}
+		var err error
+		gift, err = q.CreateGift(req.Context(), database.CreateGiftParams{
+			RecipientID:  recipientID,
+			SenderID:     uuid.NullUUID{UUID: adminID, Valid: true},
+			Plan:         plan,
+			DurationDays: params.DurationDays,
+			Message:      params.Message,
+		})
+		if err != nil {
+			return err
+		}
+		subscription, wasChirpyRed, err = writeSubscriptionChange(req.Context(), q, recipientID,
+			grantedSubscription(plan, gift.DurationDays, subscriptionEventGifted, subscriptionSourceGift, gift.ID.String()))
+		return err
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.emitChirpyRedWebhook(req.Context(), wasChirpyRed, subscription)
+
+	dat, err := json.Marshal(response{
+		Gift:         giftFromDatabase(gift),
+		Subscription: subscriptionFromDatabase(subscription),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func promoCodeFromDatabase(promo database.PromoCode) PromoCode {
+	res := PromoCode{
+		ID:           promo.ID,
+		Code:         promo.Code,
+		Plan:         promo.Plan,
+		DurationDays: promo.DurationDays,
+		Redemptions:  promo.Redemptions,
+		CreatedAt:    promo.CreatedAt,
+	}
+	if promo.MaxRedemptions.Valid {
+		res.MaxRedemptions = &promo.MaxRedemptions.Int32
+	}
+	if promo.ExpiresAt.Valid {
+		res.ExpiresAt = &promo.ExpiresAt.Time
+	}
+	if promo.CreatedBy.Valid {
+		res.CreatedBy = &promo.CreatedBy.UUID
+	}
+	return res
+}
+
+func giftFromDatabase(gift database.Gift) Gift {
+	res := Gift{
+		ID:           gift.ID,
+		RecipientID:  gift.RecipientID,
+		Plan:         gift.Plan,
+		DurationDays: gift.DurationDays,
+		Message:      gift.Message,
+		CreatedAt:    gift.CreatedAt,
+	}
+	if gift.SenderID.Valid {
+		res.SenderID = &gift.SenderID.UUID
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/ratelimit"
+)
+
+// Route groups share a rate limit. Each identity gets its own bucket per
+// group, so posting chirps doesn't use up the allowance for reading them.
+const (
+	rateLimitAuth    = "auth"
+	rateLimitWrite   = "write"
+	rateLimitRead    = "read"
+	rateLimitWebhook = "webhook"
+)
+
+var defaultRateLimits = map[string]ratelimit.Limit{
+	rateLimitAuth:    {Requests: 10, Per: time.Minute},
+	rateLimitWrite:   {Requests: 30, Per: time.Minute},
+	rateLimitRead:    {Requests: 300, Per: time.Minute},
+	rateLimitWebhook: {Requests: 60, Per: time.Minute},
+}
+
+// loadRateLimits reads RATE_LIMIT_<GROUP> (for example RATE_LIMIT_WRITE=30/1m)
+// for each route group, falling back to the defaults.
+func loadRateLimits() map[string]ratelimit.Limit {
+	limits := map[string]ratelimit.Limit{}
+	for group, limit := range defaultRateLimits {
+		limits[group] = limit
+		value := os.Getenv("RATE_LIMIT_" + strings.ToUpper(group))
+		if value == "" {
+			continue
+		}
+		parsed, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		limits[group] = parsed
+	}
+	return limits
+}
+
+// middlewareRateLimit applies the limit of a route group. Requests are
+// counted per user when they carry a valid access token, per key when they
+// carry an API key, and per client IP otherwise. Users get the group's limit
+// multiplied by their plan's rate limit multiplier.
+func (cfg *apiConfig) middlewareRateLimit(group string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		limit := cfg.rateLimits[group]
+		identity, multiplier := cfg.rateLimitIdentity(req)
+		if multiplier != 1 {
+			limit = limit.Scale(multiplier)
+		}
+
+		decision := cfg.rateLimiter.Allow(group+":"+identity, limit)
+		decision.WriteHeaders(w.Header())
+		if !decision.Allowed {
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+			return
+		}
+		next(w, req)
+	}
+}
+
+func (cfg *apiConfig) rateLimitIdentity(req *http.Request) (string, float64) {
+	if token, err := auth.GetBearerToken(req.Header); err == nil {
+		if userID, err := auth.ValidateJWT(token, cfg.jwt_secret); err == nil {
+			// Looked up on every request so an upgrade to Chirpy Red applies
+			// straight away rather than on the next token refresh.
+			capabilities, err := cfg.capabilities(req.Context(), userID)
+			if err != nil {
+				return "user:" + userID.String(), 1
+			}
+			return "user:" + userID.String(), capabilities.RateLimitMultiplier
+		}
+	}
+	if key, err := auth.GetAPIKey(req.Header); err == nil {
+		return "key:" + auth.HashToken(key)[:16], 1
+	}
+	return "ip:" + cfg.trustedProxies.ClientIP(req), 1
+}
@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+// errReportResolved means another moderator resolved the report first.
+var errReportResolved = errors.New("report has already been resolved")
+
+var reportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "self_harm", "misinformation", "other"}
+
+const (
+	resolutionDismiss       = "dismiss"
+	resolutionHideChirp     = "hide_chirp"
+	resolutionDeleteChirp   = "delete_chirp"
+	resolutionSuspendAuthor = "suspend_author"
+
+	defaultSuspension   = 7 * 24 * time.Hour
+	reportContextChirps = 10
+)
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ChirpID    *uuid.UUID `json:"chirp_id"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	Resolution *string    `json:"resolution,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ModeratedChirp is a chirp as moderators see it, including its moderation
+// status.
+type ModeratedChirp struct {
+	Chirp
+	ModerationStatus string `json:"moderation_status"`
+}
+
+type ModerationAction struct {
+	ID           uuid.UUID  `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ModeratorID  uuid.UUID  `json:"moderator_id"`
+	Action       string     `json:"action"`
+	ReportID     *uuid.UUID `json:"report_id,omitempty"`
+	ChirpID      *uuid.UUID `json:"chirp_id,omitempty"`
+	TargetUserID *uuid.UUID `json:"target_user_id,omitempty"`
+	Note         string     `json:"note"`
+}
+
+func (cfg *apiConfig) reportChirp(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	chirpID, err := uuid.Parse(req.PathValue("chirpID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !slices.Contains(reportReasons, params.Reason) {
+		respondWithError(w, http.StatusBadRequest, "Unknown report reason")
+		return
+	}
+	if len(params.Details) > 1000 {
+		respondWithError(w, http.StatusBadRequest, "Details are too long")
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpByID(req.Context(), database.GetChirpByIDParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if chirp.UserID == userID {
+		respondWithError(w, http.StatusBadRequest, "You can't report your own chirp")
+		return
+	}
+
+	report, err := cfg.db.CreateReport(req.Context(), database.CreateReportParams{
+		ChirpID:    uuid.NullUUID{UUID: chirpID, Valid: true},
+		ReporterID: userID,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "You have already reported this chirp")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(reportFromDatabase(report))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) getReports(w http.ResponseWriter, req *http.Request) {
+	status := req.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if status != "open" && status != "resolved" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rows, err := cfg.db.GetReportsByStatus(req.Context(), status)
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	reports := []Report{}
+	for _, row := range rows {
+		reports = append(reports, reportFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(reports)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// getReport returns a report together with the context a moderator needs to
+// judge it: the chirp (whatever its status), its author, the author's recent
+// chirps and every other report filed against the same chirp.
+func (cfg *apiConfig) getReport(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		Report       Report           `json:"report"`
+		Chirp        *ModeratedChirp  `json:"chirp"`
+		Author       *ModeratedUser   `json:"author"`
+		RecentChirps []ModeratedChirp `json:"author_recent_chirps"`
+		ChirpReports []Report         `json:"chirp_reports"`
+	}
+
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	report, err := cfg.db.GetReport(req.Context(), reportID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	res := response{
+		Report:       reportFromDatabase(report),
+		RecentChirps: []ModeratedChirp{},
+		ChirpReports: []Report{},
+	}
+	// The chirp is gone once a report has been resolved by deleting it.
+	if report.ChirpID.Valid {
+		chirp, err := cfg.db.GetChirpByIDAnyStatus(req.Context(), report.ChirpID.UUID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		moderated := moderatedChirpFromDatabase(chirp)
+		res.Chirp = &moderated
+
+		user, err := cfg.db.GetUserByID(req.Context(), chirp.UserID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		author := moderatedUserFromDatabase(user)
+		res.Author = &author
+
+		recent, err := cfg.db.GetRecentChirpsByUserID(req.Context(), database.GetRecentChirpsByUserIDParams{
+			UserID: chirp.UserID,
+			Limit:  reportContextChirps,
+		})
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, c := range recent {
+			res.RecentChirps = append(res.RecentChirps, moderatedChirpFromDatabase(c))
+		}
+
+		others, err := cfg.db.GetReportsByChirpID(req.Context(), report.ChirpID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, other := range others {
+			res.ChirpReports = append(res.ChirpReports, reportFromDatabase(other))
+		}
+	}
+
+	dat, err := json.Marshal(res)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) resolveReport(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+		// SuspendFor is a Go duration such as "72h", only used with
+		// suspend_author.
+		SuspendFor string `json:"suspend_for"`
+	}
+
+	moderator, ok := cfg.currentUser(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	moderatorID := moderator.ID
+	reportID, err := uuid.Parse(req.PathValue("reportID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	suspendFor := defaultSuspension
+	switch params.Action {
+	case resolutionDismiss, resolutionHideChirp, resolutionDeleteChirp:
+	case resolutionSuspendAuthor:
+		if params.SuspendFor != "" {
+			suspendFor, err = time.ParseDuration(params.SuspendFor)
+			if err != nil || suspendFor <= 0 {
+				respondWithError(w, http.StatusBadRequest, "Invalid suspension duration")
+				return
+			}
+		}
+	default:
+		respondWithError(w, http.StatusBadRequest, "Unknown action")
+		return
+	}
+
+	report, err := cfg.db.GetReport(req.Context(), reportID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if report.Status != "open" {
+		respondWithError(w, http.StatusConflict, "Report has already been resolved")
+		return
+	}
+	var chirp database.Chirp
+	if params.Action != resolutionDismiss {
+		if !report.ChirpID.Valid {
+			respondWithError(w, http.StatusConflict, "The reported chirp no longer exists")
+			return
+		}
+		chirp, err = cfg.db.GetChirpByIDAnyStatus(req.Context(), report.ChirpID.UUID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	if params.Action == resolutionSuspendAuthor {
+		author, err := cfg.db.GetUserByID(req.Context(), chirp.UserID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !auth.Outranks(moderator.Role, author.Role) {
+			respondWithError(w, http.StatusForbidden, "You can only moderate users with a lower role than yours")
+			return
+		}
+	}
+
+	// The report, the action against the chirp or its author and the audit
+	// entry are written together. Resolving first means two moderators
+	// acting on the same report can't both apply their action.
+	var resolved database.Report
+	err = cfg.inTx(req.Context(), func(q *database.Queries) error {
+		resolved, err = q.ResolveReport(req.Context(), database.ResolveReportParams{
+			Resolution: sql.NullString{String: params.Action, Valid: true},
+			ResolvedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+			ID:         reportID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errReportResolved
+		}
+		if err != nil {
+			return err
+		}
+
+		audit := database.CreateModerationActionParams{
+			ModeratorID: moderatorID,
+			Action:      params.Action,
+			ReportID:    uuid.NullUUID{UUID: reportID, Valid: true},
+			ChirpID:     report.ChirpID,
+			Note:        params.Note,
+		}
+		if params.Action != resolutionDismiss {
+			audit.TargetUserID = uuid.NullUUID{UUID: chirp.UserID, Valid: true}
+			err = applyReportResolution(req.Context(), q, params.Action, chirp, moderatorID, params.Note, suspendFor)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = q.CreateModerationAction(req.Context(), audit)
+		return err
+	})
+	if errors.Is(err, errReportResolved) {
+		respondWithError(w, http.StatusConflict, "Report has already been resolved")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	switch params.Action {
+	case resolutionHideChirp:
+		cfg.withdrawChirp(req.Context(), chirp.ID)
+	case resolutionDeleteChirp:
+		cfg.announceChirpRemoved(req.Context(), chirp)
+	}
+
+	dat, err := json.Marshal(reportFromDatabase(resolved))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// applyReportResolution carries out an action against the reported chirp or
+// its author. Any other open reports against the same chirp are resolved with
+// it, since the moderator has dealt with the chirp as a whole.
+func applyReportResolution(ctx context.Context, q *database.Queries, action string, chirp database.Chirp, moderatorID uuid.UUID, note string, suspendFor time.Duration) error {
+	_, err := q.ResolveOpenReportsForChirp(ctx, database.ResolveOpenReportsForChirpParams{
+		Resolution: sql.NullString{String: action, Valid: true},
+		ResolvedBy: uuid.NullUUID{UUID: moderatorID, Valid: true},
+		ChirpID:    uuid.NullUUID{UUID: chirp.ID, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case resolutionHideChirp:
+		_, err = q.UpdateChirpModerationStatus(ctx, database.UpdateChirpModerationStatusParams{
+			ModerationStatus: "hidden",
+			ID:               chirp.ID,
+		})
+		return err
+	case resolutionDeleteChirp:
+		return q.DeleteChirp(ctx, chirp.ID)
+	case resolutionSuspendAuthor:
+		_, err = suspendUser(ctx, q, chirp.UserID, time.Now().Add(suspendFor), note)
+		return err
+	}
+	return nil
+}
+
+func (cfg *apiConfig) getModerationActions(w http.ResponseWriter, req *http.Request) {
+	limit := 100
+	if s := req.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := cfg.db.GetModerationActions(req.Context(), int32(limit))
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	actions := []ModerationAction{}
+	for _, row := range rows {
+		actions = append(actions, moderationActionFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(actions)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func reportFromDatabase(report database.Report) Report {
+	res := Report{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		UpdatedAt:  report.UpdatedAt,
+		ReporterID: report.ReporterID,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+	}
+	if report.ChirpID.Valid {
+		res.ChirpID = &report.ChirpID.UUID
+	}
+	if report.Resolution.Valid {
+		res.Resolution = &report.Resolution.String
+	}
+	if report.ResolvedBy.Valid {
+		res.ResolvedBy = &report.ResolvedBy.UUID
+	}
+	if report.ResolvedAt.Valid {
+		res.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return res
+}
+
+func moderatedChirpFromDatabase(chirp database.Chirp) ModeratedChirp {
+	return ModeratedChirp{
+		Chirp:            chirpFromDatabase(chirp),
+		ModerationStatus: chirp.ModerationStatus,
+	}
+}
+
+func moderationActionFromDatabase(action database.ModerationAction) ModerationAction {
+	res := ModerationAction{
+		ID:          action.ID,
+		CreatedAt:   action.CreatedAt,
+		ModeratorID: action.ModeratorID,
+		Action:      action.Action,
+		Note:        action.Note,
+	}
+	if action.ReportID.Valid {
+		res.ReportID = &action.ReportID.UUID
+	}
+	if action.ChirpID.Valid {
+		res.ChirpID = &action.ChirpID.UUID
+	}
+	if action.TargetUserID.Valid {
+		res.TargetUserID = &action.TargetUserID.UUID
+	}
+	return res
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+// middlewareRequireRole only lets requests through from users currently
+// holding at least the required role. The role is read from the database
+// rather than the access token, so demoting or deleting a user takes effect
+// straight away. Suspended staff can't use any admin endpoint, since even
+// reading reports is part of moderating.
+func (cfg *apiConfig) middlewareRequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		user, ok := cfg.currentUser(req)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !auth.HasRole(user.Role, role) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if isSuspended(user) {
+			respondAccountSuspended(w, user)
+			return
+		}
+		next(w, req)
+	}
+}
+
+// currentUser loads the user the request's access token was issued to.
+func (cfg *apiConfig) currentUser(req *http.Request) (database.User, bool) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		return database.User{}, false
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(req.Context(), userID)
+	if err != nil {
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) updateUserRole(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Role string `json:"role"`
+	}
+	type response struct {
+		User
+		Role string `json:"role"`
+	}
+
+	userID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err = decoder.Decode(&params)
+	if err != nil || !auth.IsValidRole(params.Role) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, err := cfg.db.UpdateUserRole(req.Context(), database.UpdateUserRoleParams{
+		Role: params.Role,
+		ID:   userID,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	dat, err := json.Marshal(response{
+		User: User{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+		},
+		Role: user.Role,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+// runCommand handles the administrative subcommands of the chirpy binary,
+// e.g. `chirpy grant-admin user@example.com` to bootstrap the first admin.
+func (cfg *apiConfig) runCommand(args []string) error {
+	switch args[0] {
+	case "grant-admin", "grant-moderator":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: chirpy %s <email>", args[0])
+		}
+		role := auth.RoleAdmin
+		if args[0] == "grant-moderator" {
+			role = auth.RoleModerator
+		}
+		user, err := cfg.db.UpdateUserRoleByEmail(context.Background(), database.UpdateUserRoleByEmailParams{
+			Role:  role,
+			Email: args[1],
+		})
+		if err != nil {
+			return fmt.Errorf("could not grant %s to %s: %w", role, args[1], err)
+		}
+		fmt.Printf("%s is now %s\n", user.Email, user.Role)
+		return nil
+	default:
+		return errors.New("unknown command: " + args[0])
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/spam"
+)
+
+// loadSpamConfig reads the SPAM_* settings, keeping the default for any that
+// are missing or invalid.
+func loadSpamConfig() spam.Config {
+	config := spam.DefaultConfig()
+	envDuration("SPAM_DUPLICATE_WINDOW", &config.DuplicateWindow)
+	envFloat("SPAM_DUPLICATE_SIMILARITY", &config.DuplicateSimilarity)
+	envSpamAction("SPAM_DUPLICATE_ACTION", &config.DuplicateAction)
+	envInt("SPAM_MAX_LINKS", &config.MaxLinks)
+	envFloat("SPAM_MAX_LINK_DENSITY", &config.MaxLinkDensity)
+	envSpamAction("SPAM_LINK_ACTION", &config.LinkAction)
+	envInt("SPAM_VELOCITY_LIMIT", &config.VelocityLimit)
+	envDuration("SPAM_VELOCITY_WINDOW", &config.VelocityWindow)
+	envSpamAction("SPAM_VELOCITY_ACTION", &config.VelocityAction)
+	return config
+}
+
+func envDuration(name string, dst *time.Duration) {
+	if value := os.Getenv(name); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			return
+		}
+		*dst = d
+	}
+}
+
+func envFloat(name string, dst *float64) {
+	if value := os.Getenv(name); value != "" {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			return
+		}
+		*dst = f
+	}
+}
+
+func envInt(name string, dst *int) {
+	if value := os.Getenv(name); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			return
+		}
+		*dst = n
+	}
+}
+
+func envSpamAction(name string, dst *spam.Action) {
+	if value := os.Getenv(name); value != "" {
+		action, err := spam.ParseAction(value)
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			return
+		}
+		*dst = action
+	}
+}
+
+// checkSpam judges a new chirp against everything the user posted within the
+// spam checks' lookback window, whatever its moderation status. Chirps are
+// stored with banned words masked, so body must be the moderated body too, or
+// a repeated chirp containing a banned word never looks like a duplicate.
+// When a chirp is edited, editing is its ID, so it isn't compared with
+// itself; new chirps pass uuid.Nil.
+func (cfg *apiConfig) checkSpam(ctx context.Context, userID uuid.UUID, body string, editing uuid.UUID) (spam.Verdict, error) {
+	now := time.Now()
+	chirps, err := cfg.db.GetChirpsByUserIDSince(ctx, database.GetChirpsByUserIDSinceParams{
+		UserID: userID,
+		Since:  now.Add(-cfg.spam.Lookback()),
+	})
+	if err != nil {
+		return spam.Verdict{}, err
+	}
+	recent := []spam.Post{}
+	for _, chirp := range chirps {
+		if chirp.ID == editing {
+			continue
+		}
+		recent = append(recent, spam.Post{Body: chirp.Body, CreatedAt: chirp.CreatedAt})
+	}
+	return cfg.spam.Check(body, recent, now), nil
+}
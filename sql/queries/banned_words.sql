@@ -0,0 +1,10 @@
+-- name: GetBannedWords :many
+SELECT * FROM banned_words ORDER BY word ASC;
+
+-- name: UpsertBannedWord :one
+INSERT INTO banned_words (word, created_at, action) VALUES ($1, NOW(), $2)
+ON CONFLICT (word) DO UPDATE SET action = EXCLUDED.action
+RETURNING *;
+
+-- name: DeleteBannedWord :execrows
+DELETE FROM banned_words WHERE word = $1;
@@ -0,0 +1,22 @@
+-- name: CreateUserBlock :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at) VALUES ($1, $2, NOW())
+ON CONFLICT (blocker_id, blocked_id) DO NOTHING;
+
+-- name: DeleteUserBlock :execrows
+DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetUserBlocks :many
+SELECT * FROM user_blocks WHERE blocker_id = $1 ORDER BY created_at ASC;
+
+-- name: CreateUserMute :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at) VALUES ($1, $2, NOW())
+ON CONFLICT (muter_id, muted_id) DO NOTHING;
+
+-- name: DeleteUserMute :execrows
+DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetUserMutes :many
+SELECT * FROM user_mutes WHERE muter_id = $1 ORDER BY created_at ASC;
+
+-- name: GetUserBlockers :many
+SELECT * FROM user_blocks WHERE blocked_id = $1;
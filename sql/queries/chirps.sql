@@ -1,22 +1,152 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id) VALUES (gen_random_uuid(),NOW(),NOW(),$1,$2) RETURNING *;
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, moderation_status, content_warning, sensitive)
+VALUES (gen_random_uuid(), COALESCE(sqlc.narg(publish_at)::timestamp, NOW()), NOW(), sqlc.arg(body), sqlc.arg(user_id), sqlc.arg(moderation_status), sqlc.narg(content_warning), sqlc.arg(sensitive))
+RETURNING *;
 
 -- name: DeleteChirps :exec
 DELETE FROM chirps;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = sqlc.narg(viewer_id)))
+  AND (NOT users.shadow_banned OR users.id = sqlc.narg(viewer_id))
+  AND (chirps.created_at <= NOW() OR users.id = sqlc.narg(viewer_id))
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.narg(viewer_id))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.narg(viewer_id) AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at ASC;
 
 -- name: GetChirpByID :one
-SELECT * FROM chirps WHERE id = $1;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.id = sqlc.arg(id) AND users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = sqlc.narg(viewer_id)))
+  AND (NOT users.shadow_banned OR users.id = sqlc.narg(viewer_id))
+  AND (chirps.created_at <= NOW() OR users.id = sqlc.narg(viewer_id))
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.narg(viewer_id))
+  );
 
 -- name: DeleteChirp :exec
 DELETE FROM chirps WHERE id = $1;
 
 -- name: GetChirpsByUserID :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = sqlc.arg(user_id) AND users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = sqlc.narg(viewer_id)))
+  AND (NOT users.shadow_banned OR users.id = sqlc.narg(viewer_id))
+  AND (chirps.created_at <= NOW() OR users.id = sqlc.narg(viewer_id))
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.narg(viewer_id))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.narg(viewer_id) AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at ASC;
 
 
 
 -- name: GetChirpsDesc :many
-SELECT * FROM chirps ORDER BY created_at DESC;
\ No newline at end of file
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE users.deletion_requested_at IS NULL
+  AND (chirps.moderation_status IN ('visible', 'flagged') OR (chirps.moderation_status = 'held' AND users.id = sqlc.narg(viewer_id)))
+  AND (NOT users.shadow_banned OR users.id = sqlc.narg(viewer_id))
+  AND (chirps.created_at <= NOW() OR users.id = sqlc.narg(viewer_id))
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE (user_blocks.blocker_id = sqlc.narg(viewer_id) AND user_blocks.blocked_id = chirps.user_id)
+       OR (user_blocks.blocker_id = chirps.user_id AND user_blocks.blocked_id = sqlc.narg(viewer_id))
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = sqlc.narg(viewer_id) AND user_mutes.muted_id = chirps.user_id
+  )
+ORDER BY chirps.created_at DESC;
+
+-- name: GetAllChirpsByUserID :many
+SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at ASC;
+
+-- name: GetChirpsPage :many
+SELECT * FROM chirps
+WHERE (created_at, id) > (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_id)::uuid)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(page_size)::int;
+
+-- name: GetChirpByIDAnyStatus :one
+SELECT * FROM chirps WHERE id = $1;
+
+-- name: GetRecentChirpsByUserID :many
+SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2;
+
+-- name: UpdateChirpModerationStatus :one
+UPDATE chirps SET moderation_status = $1, updated_at = NOW() WHERE id = $2 RETURNING *;
+
+-- name: GetChirpsByUserIDSince :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg(user_id) AND created_at > sqlc.arg(since)::timestamp
+ORDER BY created_at DESC;
+
+-- name: GetChirpsByModerationStatus :many
+SELECT * FROM chirps WHERE moderation_status = $1 ORDER BY created_at ASC;
+
+-- name: UpdateChirpLabels :one
+UPDATE chirps SET content_warning = $1, sensitive = $2, updated_at = NOW() WHERE id = $3 RETURNING *;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, moderation_status = $2, edited_at = NOW(), updated_at = NOW() WHERE id = $3 RETURNING *;
+
+-- name: PinChirp :one
+UPDATE chirps SET pinned_at = NOW()
+WHERE id = sqlc.arg(id)
+  AND (SELECT COUNT(*) FROM chirps pinned WHERE pinned.user_id = chirps.user_id AND pinned.pinned_at IS NOT NULL) < sqlc.arg(max_pinned)::int
+RETURNING *;
+
+-- name: UnpinChirp :one
+UPDATE chirps SET pinned_at = NULL WHERE id = $1 RETURNING *;
+
+-- name: LockUser :exec
+SELECT id FROM users WHERE id = $1 FOR UPDATE;
+
+-- name: AnnounceChirp :one
+UPDATE chirps SET announced_at = NOW()
+WHERE id = $1 AND announced_at IS NULL AND created_at <= NOW() AND moderation_status IN ('visible', 'flagged')
+RETURNING *;
+
+-- name: WithdrawChirp :one
+UPDATE chirps SET announced_at = NULL
+WHERE id = $1 AND announced_at IS NOT NULL AND moderation_status NOT IN ('visible', 'flagged')
+RETURNING *;
+
+-- name: AnnounceDueChirps :many
+UPDATE chirps SET announced_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE announced_at IS NULL AND created_at <= NOW() AND moderation_status IN ('visible', 'flagged')
+    ORDER BY created_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: DeleteChirpsOfPurgedUsers :many
+DELETE FROM chirps
+WHERE user_id IN (
+    SELECT id FROM users
+    WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at < sqlc.arg(cutoff)::timestamp
+)
+RETURNING *;
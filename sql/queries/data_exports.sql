@@ -0,0 +1,18 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status, expires_at) VALUES (gen_random_uuid(), NOW(), NOW(), $1, 'pending', $2) RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports WHERE id = $1 AND user_id = $2;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports SET status = 'ready', archive = $1, expires_at = $2, updated_at = NOW() WHERE id = $3 AND status = 'pending';
+
+-- name: FailDataExport :exec
+UPDATE data_exports SET status = 'failed', failure_reason = $1, expires_at = $2, updated_at = NOW() WHERE id = $3 AND status = 'pending';
+
+-- name: FailStaleDataExports :execrows
+UPDATE data_exports SET status = 'failed', failure_reason = 'Export was interrupted', expires_at = $1, updated_at = NOW()
+WHERE status = 'pending' AND expires_at < NOW();
+
+-- name: DeleteExpiredDataExports :execrows
+DELETE FROM data_exports WHERE status <> 'pending' AND expires_at < NOW();
@@ -0,0 +1,10 @@
+-- name: CreateMagicLink :exec
+INSERT INTO magic_links (token_hash, created_at, user_id, fingerprint_hash, expires_at) VALUES ($1, NOW(), $2, $3, $4);
+
+-- name: ConsumeMagicLink :one
+UPDATE magic_links SET used_at = NOW()
+WHERE token_hash = $1 AND fingerprint_hash = $2 AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: DeleteExpiredMagicLinks :execrows
+DELETE FROM magic_links WHERE expires_at < NOW();
@@ -0,0 +1,21 @@
+-- name: CreatePasskeyCredential :one
+INSERT INTO passkey_credentials (id, created_at, updated_at, user_id, credential, sign_count) VALUES ($1, NOW(), NOW(), $2, $3, $4) RETURNING *;
+
+-- name: GetPasskeyCredentialsByUserID :many
+SELECT * FROM passkey_credentials WHERE user_id = $1 ORDER BY created_at ASC;
+
+-- name: UpdatePasskeySignCount :execrows
+UPDATE passkey_credentials SET sign_count = sqlc.arg(sign_count), credential = sqlc.arg(credential), last_used_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg(id) AND (sign_count < sqlc.arg(sign_count) OR sqlc.arg(sign_count) = 0);
+
+-- name: DeletePasskeyCredential :execrows
+DELETE FROM passkey_credentials WHERE id = $1 AND user_id = $2;
+
+-- name: CreateWebauthnSession :one
+INSERT INTO webauthn_sessions (id, created_at, user_id, ceremony, session_data, expires_at) VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4) RETURNING id;
+
+-- name: ConsumeWebauthnSession :one
+DELETE FROM webauthn_sessions WHERE id = $1 AND ceremony = $2 RETURNING *;
+
+-- name: DeleteExpiredWebauthnSessions :execrows
+DELETE FROM webauthn_sessions WHERE expires_at < NOW();
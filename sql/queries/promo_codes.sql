@@ -0,0 +1,37 @@
+-- name: CreatePromoCode :one
+INSERT INTO promo_codes (id, code, plan, duration_days, max_redemptions, redemptions, expires_at, created_by, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, 0, $5, $6, NOW())
+RETURNING *;
+
+-- name: GetPromoCodeByCode :one
+SELECT * FROM promo_codes WHERE code = $1;
+
+-- name: GetPromoCode :one
+SELECT * FROM promo_codes WHERE id = $1;
+
+-- name: GetPromoCodes :many
+SELECT * FROM promo_codes ORDER BY created_at DESC;
+
+-- name: ClaimPromoCode :one
+UPDATE promo_codes SET redemptions = redemptions + 1
+WHERE id = $1
+  AND (max_redemptions IS NULL OR redemptions < max_redemptions)
+  AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING *;
+
+-- name: CreatePromoRedemption :one
+INSERT INTO promo_redemptions (id, promo_code_id, user_id, redeemed_at)
+VALUES (gen_random_uuid(), $1, $2, NOW())
+ON CONFLICT (promo_code_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetPromoRedemptionsByCode :many
+SELECT promo_redemptions.*, users.email FROM promo_redemptions
+JOIN users ON users.id = promo_redemptions.user_id
+WHERE promo_redemptions.promo_code_id = $1
+ORDER BY promo_redemptions.redeemed_at DESC;
+
+-- name: CreateGift :one
+INSERT INTO gifts (id, recipient_id, sender_id, plan, duration_days, message, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW())
+RETURNING *;
@@ -6,3 +6,9 @@ SELECT * FROM refresh_tokens WHERE token = $1;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW() WHERE token = $1;
+
+-- name: RevokeRefreshTokensForUser :exec
+UPDATE refresh_tokens SET updated_at = NOW(), revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUserID :many
+SELECT * FROM refresh_tokens WHERE user_id = $1 ORDER BY created_at ASC;
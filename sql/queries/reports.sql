@@ -0,0 +1,34 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, chirp_id, reporter_id, reason, details)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4)
+ON CONFLICT (chirp_id, reporter_id) DO NOTHING
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports WHERE id = $1;
+
+-- name: GetReportsByStatus :many
+SELECT * FROM reports WHERE status = $1 ORDER BY created_at ASC;
+
+-- name: GetReportsByChirpID :many
+SELECT * FROM reports WHERE chirp_id = $1 ORDER BY created_at ASC;
+
+-- name: GetReportsByReporterID :many
+SELECT * FROM reports WHERE reporter_id = $1 ORDER BY created_at ASC;
+
+-- name: ResolveReport :one
+UPDATE reports SET status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE id = $3 AND status = 'open'
+RETURNING *;
+
+-- name: ResolveOpenReportsForChirp :execrows
+UPDATE reports SET status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = NOW(), updated_at = NOW()
+WHERE chirp_id = $3 AND status = 'open';
+
+-- name: CreateModerationAction :one
+INSERT INTO moderation_actions (id, created_at, moderator_id, action, report_id, chirp_id, target_user_id, note)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetModerationActions :many
+SELECT * FROM moderation_actions ORDER BY created_at DESC LIMIT $1;
@@ -0,0 +1,30 @@
+-- name: GetSubscriptionByUserID :one
+SELECT * FROM subscriptions WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_start, current_period_end, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, NOW(), NOW())
+ON CONFLICT (user_id) DO UPDATE SET
+    plan = EXCLUDED.plan,
+    status = EXCLUDED.status,
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: GetLapsedSubscriptions :many
+SELECT * FROM subscriptions
+WHERE (status IN ('active', 'past_due') AND current_period_end < sqlc.arg(renewal_deadline))
+   OR (status IN ('cancelled', 'granted') AND current_period_end < sqlc.arg(now));
+
+-- name: ExpireSubscription :one
+UPDATE subscriptions SET status = 'expired', updated_at = NOW()
+WHERE id = $1 AND status = $2 AND current_period_end = $3
+RETURNING *;
+
+-- name: CreateSubscriptionHistory :exec
+INSERT INTO subscription_history (id, subscription_id, user_id, event, plan, status, period_start, period_end, source, source_event_id, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7, $8, $9, NOW());
+
+-- name: GetSubscriptionHistoryByUserID :many
+SELECT * FROM subscription_history WHERE user_id = $1 ORDER BY created_at;
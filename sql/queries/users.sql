@@ -11,5 +11,35 @@ SELECT * FROM users WHERE email = $1;
 -- name: UpdateUser :one
 UPDATE users SET email = $1, hashed_password = $2 WHERE id = $3 RETURNING *;
 
--- name: UpgradeUserToChirpyRed :one
-UPDATE users SET is_chirpy_red = TRUE WHERE id = $1 RETURNING *;
\ No newline at end of file
+-- name: SetUserChirpyRed :one
+UPDATE users SET is_chirpy_red = $1 WHERE id = $2 RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: UpdateUserRole :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2 RETURNING *;
+
+-- name: UpdateUserRoleByEmail :one
+UPDATE users SET role = $1, updated_at = NOW() WHERE email = $2 RETURNING *;
+
+-- name: RequestUserDeletion :one
+UPDATE users SET deletion_requested_at = NOW(), updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_requested_at = NULL, updated_at = NOW() WHERE id = $1;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at < sqlc.arg(cutoff)::timestamp;
+
+-- name: SuspendUser :one
+UPDATE users SET suspended_until = $1, suspension_reason = $2, updated_at = NOW() WHERE id = $3 RETURNING *;
+
+-- name: LiftUserSuspension :one
+UPDATE users SET suspended_until = NULL, suspension_reason = NULL, updated_at = NOW() WHERE id = $1 RETURNING *;
+
+-- name: SetUserShadowBanned :one
+UPDATE users SET shadow_banned = $1, updated_at = NOW() WHERE id = $2 RETURNING *;
+
+-- name: UpdateUserSensitiveContent :one
+UPDATE users SET sensitive_content = $1, updated_at = NOW() WHERE id = $2 RETURNING *;
@@ -0,0 +1,59 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, url, secret, events, description, active, created_by, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, TRUE, $5, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints WHERE id = $1;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints ORDER BY created_at ASC;
+
+-- name: GetWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints WHERE active AND sqlc.arg(event_type)::text = ANY(events);
+
+-- name: UpdateWebhookEndpoint :one
+UPDATE webhook_endpoints SET url = $1, events = $2, description = $3, active = $4, updated_at = NOW()
+WHERE id = $5
+RETURNING *;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints WHERE id = $1;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, 'pending', 0, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries WHERE id = $1;
+
+-- name: GetWebhookDeliveriesByEndpoint :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+  AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_deliveries)::int;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg(lease_until)::timestamp
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= sqlc.arg(now)::timestamp
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)::int
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookDeliveryAttempt :one
+UPDATE webhook_deliveries SET
+    status = sqlc.arg(status),
+    attempts = attempts + 1,
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    last_attempt_at = NOW(),
+    response_status = sqlc.narg(response_status),
+    last_error = sqlc.narg(last_error),
+    delivered_at = CASE WHEN sqlc.arg(status) = 'delivered' THEN NOW() ELSE NULL END
+WHERE id = sqlc.arg(id) AND status = 'pending' AND next_attempt_at = sqlc.arg(lease_until)::timestamp
+RETURNING *;
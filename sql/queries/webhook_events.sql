@@ -0,0 +1,28 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, source, event_id, event_type, payload, received_at, status, attempts)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), 'received', 0)
+ON CONFLICT (source, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events WHERE id = $1;
+
+-- name: GetWebhookEventBySourceID :one
+SELECT * FROM webhook_events WHERE source = $1 AND event_id = $2;
+
+-- name: ClaimWebhookEvent :one
+UPDATE webhook_events SET status = 'processing', claimed_at = NOW()
+WHERE id = $1
+  AND (status IN ('received', 'failed') OR (status = 'processing' AND claimed_at < NOW() - INTERVAL '5 minutes'))
+RETURNING *;
+
+-- name: FinishWebhookEvent :one
+UPDATE webhook_events SET status = $1, error = $2, attempts = attempts + 1, processed_at = NOW()
+WHERE id = $3
+RETURNING *;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text
+ORDER BY received_at DESC
+LIMIT sqlc.arg(max_events)::int;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE banned_words (
+    word TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    action TEXT NOT NULL DEFAULT 'mask' CHECK (action IN ('mask', 'reject', 'flag'))
+);
+
+INSERT INTO banned_words (word, created_at, action) VALUES
+    ('kerfuffle', NOW(), 'mask'),
+    ('sharbert', NOW(), 'mask'),
+    ('fornax', NOW(), 'mask');
+
+ALTER TABLE chirps ADD COLUMN moderation_status TEXT NOT NULL DEFAULT 'visible' CHECK (moderation_status IN ('visible', 'flagged'));
+
+-- +goose Down
+ALTER TABLE chirps DROP COLUMN moderation_status;
+DROP TABLE banned_words;
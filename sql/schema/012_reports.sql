@@ -0,0 +1,41 @@
+-- +goose Up
+ALTER TABLE chirps DROP CONSTRAINT chirps_moderation_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_moderation_status_check CHECK (moderation_status IN ('visible', 'flagged', 'hidden'));
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    -- Kept after the chirp is deleted so resolved reports stay on record.
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'sexual', 'self_harm', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved')),
+    resolution TEXT CHECK (resolution IN ('dismiss', 'hide_chirp', 'delete_chirp', 'suspend_author')),
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMP,
+    UNIQUE (chirp_id, reporter_id)
+);
+
+CREATE INDEX reports_open_idx ON reports (created_at) WHERE status = 'open';
+
+-- moderation_actions is an append-only audit log. It deliberately has no
+-- foreign keys so entries outlive the chirps and users they refer to.
+CREATE TABLE moderation_actions (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    moderator_id UUID NOT NULL,
+    action TEXT NOT NULL,
+    report_id UUID,
+    chirp_id UUID,
+    target_user_id UUID,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+UPDATE chirps SET moderation_status = 'flagged' WHERE moderation_status = 'hidden';
+ALTER TABLE chirps DROP CONSTRAINT chirps_moderation_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_moderation_status_check CHECK (moderation_status IN ('visible', 'flagged'));
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE chirps DROP CONSTRAINT chirps_moderation_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_moderation_status_check CHECK (moderation_status IN ('visible', 'flagged', 'hidden', 'held'));
+
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+UPDATE chirps SET moderation_status = 'hidden' WHERE moderation_status = 'held';
+ALTER TABLE chirps DROP CONSTRAINT chirps_moderation_status_check;
+ALTER TABLE chirps ADD CONSTRAINT chirps_moderation_status_check CHECK (moderation_status IN ('visible', 'flagged', 'hidden'));
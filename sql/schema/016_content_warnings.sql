@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN content_warning TEXT;
+ALTER TABLE chirps ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE users ADD COLUMN sensitive_content TEXT NOT NULL DEFAULT 'collapse' CHECK (sensitive_content IN ('expand', 'collapse', 'omit'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN sensitive_content;
+ALTER TABLE chirps DROP COLUMN sensitive;
+ALTER TABLE chirps DROP COLUMN content_warning;
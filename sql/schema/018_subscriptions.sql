@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscriptions_current_period_end_idx ON subscriptions (current_period_end) WHERE status <> 'expired';
+
+CREATE TABLE subscription_history (
+    id UUID PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES subscriptions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    period_start TIMESTAMP NOT NULL,
+    period_end TIMESTAMP NOT NULL,
+    -- Where the change came from: a billing provider, or "system" for the
+    -- expiry job.
+    source TEXT NOT NULL,
+    source_event_id TEXT,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscription_history_user_id_idx ON subscription_history (user_id, created_at);
+
+-- +goose Down
+DROP TABLE subscription_history;
+DROP TABLE subscriptions;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE chirps ADD COLUMN pinned_at TIMESTAMP;
+
+CREATE INDEX chirps_pinned_idx ON chirps (user_id) WHERE pinned_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_pinned_idx;
+ALTER TABLE chirps DROP COLUMN pinned_at;
+ALTER TABLE chirps DROP COLUMN edited_at;
@@ -0,0 +1,44 @@
+-- +goose Up
+-- Granted subscriptions come from promo codes and gifts. They aren't paid
+-- for, so like cancelled ones they end with their period.
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_status_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check CHECK (status IN ('active', 'past_due', 'cancelled', 'granted', 'expired'));
+
+CREATE TABLE promo_codes (
+    id UUID PRIMARY KEY,
+    code TEXT NOT NULL UNIQUE,
+    plan TEXT NOT NULL,
+    duration_days INTEGER NOT NULL CHECK (duration_days > 0),
+    -- NULL means unlimited.
+    max_redemptions INTEGER CHECK (max_redemptions > 0),
+    redemptions INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE promo_redemptions (
+    id UUID PRIMARY KEY,
+    promo_code_id UUID NOT NULL REFERENCES promo_codes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redeemed_at TIMESTAMP NOT NULL,
+    UNIQUE (promo_code_id, user_id)
+);
+
+CREATE TABLE gifts (
+    id UUID PRIMARY KEY,
+    recipient_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    sender_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    plan TEXT NOT NULL,
+    duration_days INTEGER NOT NULL CHECK (duration_days > 0),
+    message TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE gifts;
+DROP TABLE promo_redemptions;
+DROP TABLE promo_codes;
+UPDATE subscriptions SET status = 'cancelled' WHERE status = 'granted';
+ALTER TABLE subscriptions DROP CONSTRAINT subscriptions_status_check;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_status_check CHECK (status IN ('active', 'past_due', 'cancelled', 'expired'));
@@ -0,0 +1,13 @@
+-- +goose Up
+-- An event is claimed before it is applied, so two deliveries of the same
+-- event, or a delivery and a replay, can't both apply it. A claim older than
+-- a few minutes belongs to a request that died and can be taken over.
+ALTER TABLE webhook_events DROP CONSTRAINT webhook_events_status_check;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_status_check CHECK (status IN ('received', 'processing', 'processed', 'ignored', 'failed'));
+ALTER TABLE webhook_events ADD COLUMN claimed_at TIMESTAMP;
+
+-- +goose Down
+UPDATE webhook_events SET status = 'failed' WHERE status = 'processing';
+ALTER TABLE webhook_events DROP COLUMN claimed_at;
+ALTER TABLE webhook_events DROP CONSTRAINT webhook_events_status_check;
+ALTER TABLE webhook_events ADD CONSTRAINT webhook_events_status_check CHECK (status IN ('received', 'processed', 'ignored', 'failed'));
@@ -0,0 +1,11 @@
+-- +goose Up
+-- When a chirp was first announced to webhooks and live streams. Held and
+-- scheduled chirps are announced later, when they become public, and each
+-- chirp only once.
+ALTER TABLE chirps ADD COLUMN announced_at TIMESTAMP;
+UPDATE chirps SET announced_at = created_at WHERE created_at <= NOW() AND moderation_status IN ('visible', 'flagged');
+CREATE INDEX chirps_unannounced_idx ON chirps (created_at) WHERE announced_at IS NULL;
+
+-- +goose Down
+DROP INDEX chirps_unannounced_idx;
+ALTER TABLE chirps DROP COLUMN announced_at;
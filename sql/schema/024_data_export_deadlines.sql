@@ -0,0 +1,8 @@
+-- +goose Up
+-- Pending exports now carry the deadline for building them in expires_at.
+-- Give the ones requested before that the same hour from when they were
+-- requested, so a build that was interrupted is eventually marked failed.
+UPDATE data_exports SET expires_at = created_at + INTERVAL '1 hour' WHERE status = 'pending' AND expires_at IS NULL;
+
+-- +goose Down
+UPDATE data_exports SET expires_at = NULL WHERE status = 'pending';
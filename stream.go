@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/stream"
+)
+
+const (
+	streamChirpCreated = "chirp.created"
+	streamChirpDeleted = "chirp.deleted"
+)
+
+const (
+	// streamReplaySize is how many recent events are kept for clients
+	// resuming with Last-Event-ID.
+	streamReplaySize = 1000
+	// streamBufferSize is how many events a client can fall behind by
+	// before it is disconnected.
+	streamBufferSize  = 64
+	streamHeartbeat   = 15 * time.Second
+	streamRetryMillis = 3000
+	// hiddenAuthorsRefresh is how often a live connection reloads the
+	// viewer's blocks and mutes, so ones made mid-stream take effect
+	// without reconnecting.
+	hiddenAuthorsRefresh = 15 * time.Second
+)
+
+// announceBatch is how many due chirps announceDueChirps handles per run.
+const announceBatch = 100
+
+// announceChirp tells integrators and every replica's live streams about a
+// chirp the first time it is public. Held and scheduled chirps are skipped
+// here and announced by announceDueChirps once they are approved or due.
+func (cfg *apiConfig) announceChirp(ctx context.Context, chirp database.Chirp) {
+	chirp, err := cfg.db.AnnounceChirp(ctx, chirp.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cfg.publishAnnouncedChirp(ctx, chirp)
+}
+
+// announceDueChirps announces chirps that have become public since they
+// were created: scheduled chirps whose time has come and held chirps a
+// moderator approved.
+func (cfg *apiConfig) announceDueChirps(ctx context.Context) {
+	chirps, err := cfg.db.AnnounceDueChirps(ctx, announceBatch)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, chirp := range chirps {
+		cfg.publishAnnouncedChirp(ctx, chirp)
+	}
+}
+
+// publishAnnouncedChirp sends a chirp's announcement. Chirps by
+// shadow-banned users are claimed but never announced.
+func (cfg *apiConfig) publishAnnouncedChirp(ctx context.Context, chirp database.Chirp) {
+	author, err := cfg.db.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if author.ShadowBanned {
+		return
+	}
+	cfg.emitWebhook(ctx, webhookChirpCreated, chirpFromDatabase(chirp))
+	cfg.publishEvent(ctx, topicChirpCreated, chirpEvent{ID: chirp.ID, UserID: chirp.UserID})
+}
+
+// announceChirpRemoved tells integrators and live streams that a chirp they
+// were told about is gone, whether its author or a moderator deleted it.
+// Chirps that were never announced are skipped.
+func (cfg *apiConfig) announceChirpRemoved(ctx context.Context, chirp database.Chirp) {
+	if !chirp.AnnouncedAt.Valid {
+		return
+	}
+	cfg.publishRemovedChirp(ctx, chirp)
+}
+
+// withdrawChirp announces the removal of a chirp that stopped being public
+// without being deleted, such as one a moderator hid. It is announced again
+// if it is approved later.
+func (cfg *apiConfig) withdrawChirp(ctx context.Context, chirpID uuid.UUID) {
+	chirp, err := cfg.db.WithdrawChirp(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	cfg.publishRemovedChirp(ctx, chirp)
+}
+
+// publishRemovedChirp sends a chirp's removal. Chirps by shadow-banned users
+// were never announced, so there is nothing to take back. The authors of
+// purged accounts can't be looked up any more.
+func (cfg *apiConfig) publishRemovedChirp(ctx context.Context, chirp database.Chirp) {
+	author, err := cfg.db.GetUserByID(ctx, chirp.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		fmt.Println(err)
+		return
+	}
+	if err == nil && author.ShadowBanned {
+		return
+	}
+	cfg.emitWebhook(ctx, webhookChirpDeleted, struct {
+		ID     uuid.UUID `json:"id"`
+		UserID uuid.UUID `json:"user_id"`
+	}{ID: chirp.ID, UserID: chirp.UserID})
+	cfg.publishChirpDeleted(ctx, chirp)
+}
+
+func (cfg *apiConfig) publishChirpDeleted(ctx context.Context, chirp database.Chirp) {
+	cfg.publishEvent(ctx, topicChirpDeleted, chirpEvent{ID: chirp.ID, UserID: chirp.UserID})
+}
+
+func chirpStreamEvent(streamType string, chirp database.Chirp) stream.Event {
+	return stream.Event{
+		Type:     streamType,
+		AuthorID: chirp.UserID,
+		ThreadID: chirp.ID,
+		Hashtags: stream.Hashtags(chirp.Body),
+		Mentions: stream.Mentions(chirp.Body),
+		Payload:  chirp,
+	}
+}
+
+// hiddenAuthors returns the users whose chirps the viewer shouldn't get:
+// those they blocked or muted, and those who blocked them.
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewerID uuid.UUID) (map[uuid.UUID]bool, error) {
+	hidden := map[uuid.UUID]bool{}
+	blocks, err := cfg.db.GetUserBlocks(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blocks {
+		hidden[block.BlockedID] = true
+	}
+	blockers, err := cfg.db.GetUserBlockers(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range blockers {
+		hidden[block.BlockerID] = true
+	}
+	mutes, err := cfg.db.GetUserMutes(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, mute := range mutes {
+		hidden[mute.MutedID] = true
+	}
+	return hidden, nil
+}
+
+// refreshHiddenAuthors reloads the authors hidden from a live connection,
+// keeping the ones it has if they can't be loaded.
+func (cfg *apiConfig) refreshHiddenAuthors(ctx context.Context, viewerID uuid.NullUUID, hidden map[uuid.UUID]bool) map[uuid.UUID]bool {
+	if !viewerID.Valid {
+		return hidden
+	}
+	refreshed, err := cfg.hiddenAuthors(ctx, viewerID.UUID)
+	if err != nil {
+		fmt.Println(err)
+		return hidden
+	}
+	return refreshed
+}
+
+func (cfg *apiConfig) streamChirps(w http.ResponseWriter, req *http.Request) {
+	filter := stream.Filter{}
+	if authorID := req.URL.Query().Get("author_id"); authorID != "" {
+		authorUUID, err := uuid.Parse(authorID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		filter.AuthorID = uuid.NullUUID{UUID: authorUUID, Valid: true}
+	}
+	filter.Hashtag = strings.ToLower(strings.TrimPrefix(req.URL.Query().Get("hashtag"), "#"))
+
+	// Browsers resend the ID of the last event they saw when they
+	// reconnect. The query parameter is for clients that can't set headers.
+	lastEventID := req.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = req.URL.Query().Get("last_event_id")
+	}
+	var resumeFrom uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resumeFrom = id
+	}
+
+	viewerID := cfg.viewerID(req)
+	hidden := map[uuid.UUID]bool{}
+	if viewerID.Valid {
+		var err error
+		hidden, err = cfg.hiddenAuthors(req.Context(), viewerID.UUID)
+		if err != nil {
+			fmt.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+	preference := cfg.sensitiveContentPreference(req.Context(), viewerID)
+	expand := req.URL.Query().Get("expand") == "true"
+
+	sub, missed, complete := cfg.chirpStream.Subscribe(filter, resumeFrom)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+	// The client missed events that are no longer buffered, so it should
+	// refetch rather than trust the stream to fill the gap.
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range missed {
+		if !hidden[event.AuthorID] {
+			writeStreamEvent(w, event, viewerID, preference, expand)
+		}
+	}
+	rc.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	refresh := time.NewTicker(hiddenAuthorsRefresh)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-cfg.shutdown.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-refresh.C:
+			hidden = cfg.refreshHiddenAuthors(req.Context(), viewerID, hidden)
+			continue
+		case event, ok := <-sub.Events:
+			// Closed because the client fell too far behind. It can
+			// reconnect and resume from the replay buffer.
+			if !ok {
+				return
+			}
+			if hidden[event.AuthorID] {
+				continue
+			}
+			writeStreamEvent(w, event, viewerID, preference, expand)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event stream.Event, viewerID uuid.NullUUID, preference string, expand bool) {
+	chirp, ok := event.Payload.(database.Chirp)
+	if !ok {
+		return
+	}
+
+	var data any
+	switch event.Type {
+	case streamChirpCreated:
+		presented, ok := presentChirp(chirp, viewerID, preference, expand)
+		if !ok {
+			return
+		}
+		data = presented
+	case streamChirpDeleted:
+		data = struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{ID: chirp.ID, UserID: chirp.UserID}
+	default:
+		return
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, dat)
+}
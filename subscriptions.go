@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+)
+
+const (
+	subscriptionActive    = "active"
+	subscriptionPastDue   = "past_due"
+	subscriptionCancelled = "cancelled"
+	// subscriptionGranted is Chirpy Red given for free by a promo code or a
+	// gift. It ends with its period.
+	subscriptionGranted = "granted"
+	subscriptionExpired = "expired"
+)
+
+// Events recorded in a subscription's history.
+const (
+	subscriptionEventUpgraded      = "upgraded"
+	subscriptionEventRenewed       = "renewed"
+	subscriptionEventPaymentFailed = "payment_failed"
+	subscriptionEventCancelled     = "cancelled"
+	subscriptionEventDowngraded    = "downgraded"
+	subscriptionEventExpired       = "expired"
+	subscriptionEventPromoRedeemed = "promo_redeemed"
+	subscriptionEventGifted        = "gifted"
+)
+
+// Sources of subscription changes other than billing providers.
+const (
+	// subscriptionSourceSystem marks history written by the expiry job.
+	subscriptionSourceSystem = "system"
+	subscriptionSourcePromo  = "promo"
+	subscriptionSourceGift   = "gift"
+)
+
+type Subscription struct {
+	Plan               string    `json:"plan"`
+	Status             string    `json:"status"`
+	CurrentPeriodStart time.Time `json:"current_period_start"`
+	CurrentPeriodEnd   time.Time `json:"current_period_end"`
+}
+
+type SubscriptionHistoryEntry struct {
+	CreatedAt   time.Time `json:"created_at"`
+	Event       string    `json:"event"`
+	Plan        string    `json:"plan"`
+	Status      string    `json:"status"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Source      string    `json:"source"`
+}
+
+// subscriptionChange is one change to a user's subscription. Zero period
+// times are filled in from the current subscription, or default to a
+// month starting now.
+type subscriptionChange struct {
+	Event         string
+	Plan          string
+	Status        string
+	PeriodStart   time.Time
+	PeriodEnd     time.Time
+	Source        string
+	SourceEventID string
+	// Grant is free time given by a promo code or a gift. Time granted to
+	// a current subscription is added to the end of its period, and the
+	// subscription keeps its plan and status; otherwise a granted
+	// subscription starts now.
+	Grant time.Duration
+}
+
+// applySubscriptionChange stores a subscription change in its own
+// transaction and tells integrators if it gave or took away Chirpy Red.
+func (cfg *apiConfig) applySubscriptionChange(ctx context.Context, userID uuid.UUID, change subscriptionChange) (database.Subscription, error) {
+	var subscription database.Subscription
+	var wasChirpyRed bool
+	err := cfg.inTx(ctx, func(q *database.Queries) error {
+		var err error
+		subscription, wasChirpyRed, err = writeSubscriptionChange(ctx, q, userID, change)
+		return err
+	})
+	if err != nil {
+		return database.Subscription{}, err
+	}
+	cfg.emitChirpyRedWebhook(ctx, wasChirpyRed, subscription)
+	return subscription, nil
+}
+
+// writeSubscriptionChange stores a subscription change, keeps the user's
+// Chirpy Red flag in step with it and records it in the history. It also
+// reports whether the user had Chirpy Red before. q must be in a
+// transaction: the user is locked before the current subscription is read,
+// so concurrent changes such as a renewal and a gift are applied one after
+// the other and neither loses the other's time.
+func writeSubscriptionChange(ctx context.Context, q *database.Queries, userID uuid.UUID, change subscriptionChange) (database.Subscription, bool, error) {
+	if err := q.LockUser(ctx, userID); err != nil {
+		return database.Subscription{}, false, err
+	}
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return database.Subscription{}, false, err
+	}
+	current, err := q.GetSubscriptionByUserID(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return database.Subscription{}, false, err
+	}
+	change = resolveSubscriptionChange(change, current, err == nil, time.Now())
+
+	// The flag, the subscription and its history are written together so
+	// they can't disagree.
+	_, err = q.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{
+		IsChirpyRed: change.Status != subscriptionExpired,
+		ID:          userID,
+	})
+	if err != nil {
+		return database.Subscription{}, false, err
+	}
+	subscription, err := q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:             userID,
+		Plan:               change.Plan,
+		Status:             change.Status,
+		CurrentPeriodStart: change.PeriodStart,
+		CurrentPeriodEnd:   change.PeriodEnd,
+	})
+	if err != nil {
+		return database.Subscription{}, false, err
+	}
+	err = recordSubscriptionHistory(ctx, q, subscription, change.Event, change.Source, change.SourceEventID)
+	if err != nil {
+		return database.Subscription{}, false, err
+	}
+	return subscription, user.IsChirpyRed, nil
+}
+
+// resolveSubscriptionChange works out the plan, status and period a change
+// leaves the subscription with, given the user's current subscription if
+// hasCurrent.
+func resolveSubscriptionChange(change subscriptionChange, current database.Subscription, hasCurrent bool, now time.Time) subscriptionChange {
+	if change.Grant > 0 {
+		change.PeriodStart = now
+		change.PeriodEnd = now.Add(change.Grant)
+		if hasCurrent && current.Status != subscriptionExpired && current.CurrentPeriodEnd.After(now) {
+			change.Plan = current.Plan
+			change.Status = current.Status
+			change.PeriodStart = current.CurrentPeriodStart
+			change.PeriodEnd = current.CurrentPeriodEnd.Add(change.Grant)
+		}
+	}
+	if change.Plan == "" {
+		change.Plan = entitlements.PlanChirpyRed
+		if hasCurrent {
+			change.Plan = current.Plan
+		}
+	}
+	// Ending a subscription ends its period now.
+	if change.Status == subscriptionExpired && change.PeriodEnd.IsZero() {
+		change.PeriodEnd = now
+	}
+	switch change.Event {
+	case subscriptionEventRenewed:
+		// A renewal starts where the last period ended.
+		if change.PeriodStart.IsZero() && hasCurrent && current.CurrentPeriodEnd.After(now) {
+			change.PeriodStart = current.CurrentPeriodEnd
+		}
+	case subscriptionEventUpgraded:
+		// An upgrade always starts a fresh period.
+	default:
+		if hasCurrent {
+			if change.PeriodStart.IsZero() {
+				change.PeriodStart = current.CurrentPeriodStart
+			}
+			if change.PeriodEnd.IsZero() {
+				change.PeriodEnd = current.CurrentPeriodEnd
+			}
+		}
+	}
+	if change.PeriodStart.IsZero() {
+		change.PeriodStart = now
+	}
+	if change.PeriodEnd.IsZero() {
+		change.PeriodEnd = change.PeriodStart.AddDate(0, 1, 0)
+	}
+	// Cancelling after the paid period has already run out ends it now.
+	if change.Status == subscriptionCancelled && !change.PeriodEnd.After(now) {
+		change.Status = subscriptionExpired
+	}
+	return change
+}
+
+// emitChirpyRedWebhook tells integrators when a subscription change gave a
+// user Chirpy Red or took it away.
+func (cfg *apiConfig) emitChirpyRedWebhook(ctx context.Context, wasChirpyRed bool, subscription database.Subscription) {
+	isChirpyRed := subscription.Status != subscriptionExpired
+	if wasChirpyRed == isChirpyRed {
+		return
+	}
+	eventType := webhookUserUpgraded
+	if !isChirpyRed {
+		eventType = webhookUserDowngraded
+	}
+	cfg.emitWebhook(ctx, eventType, struct {
+		UserID uuid.UUID `json:"user_id"`
+		Plan   string    `json:"plan"`
+	}{UserID: subscription.UserID, Plan: subscription.Plan})
+}
+
+// grantedSubscription is the change that gives a user free time on a plan.
+func grantedSubscription(plan string, days int32, event, source, sourceID string) subscriptionChange {
+	return subscriptionChange{
+		Event:         event,
+		Plan:          plan,
+		Status:        subscriptionGranted,
+		Grant:         time.Duration(days) * 24 * time.Hour,
+		Source:        source,
+		SourceEventID: sourceID,
+	}
+}
+
+func recordSubscriptionHistory(ctx context.Context, q *database.Queries, subscription database.Subscription, event, source, sourceEventID string) error {
+	return q.CreateSubscriptionHistory(ctx, database.CreateSubscriptionHistoryParams{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		Event:          event,
+		Plan:           subscription.Plan,
+		Status:         subscription.Status,
+		PeriodStart:    subscription.CurrentPeriodStart,
+		PeriodEnd:      subscription.CurrentPeriodEnd,
+		Source:         source,
+		SourceEventID:  sql.NullString{String: sourceEventID, Valid: sourceEventID != ""},
+	})
+}
+
+// expireSubscriptions ends Chirpy Red for subscriptions whose paid period
+// has lapsed. Active and past due subscriptions get the renewal grace
+// period first; cancelled and granted ones end as soon as the period does.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) {
+	now := time.Now()
+	lapsed, err := cfg.db.GetLapsedSubscriptions(ctx, database.GetLapsedSubscriptionsParams{
+		RenewalDeadline: now.Add(-cfg.subscriptionGracePeriod),
+		Now:             now,
+	})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	expired := 0
+	for _, subscription := range lapsed {
+		// Only expire the subscription as it was read, so a renewal that
+		// lands in the meantime wins.
+		err = cfg.inTx(ctx, func(q *database.Queries) error {
+			subscription, err = q.ExpireSubscription(ctx, database.ExpireSubscriptionParams{
+				ID:               subscription.ID,
+				Status:           subscription.Status,
+				CurrentPeriodEnd: subscription.CurrentPeriodEnd,
+			})
+			if err != nil {
+				return err
+			}
+			_, err = q.SetUserChirpyRed(ctx, database.SetUserChirpyRedParams{
+				IsChirpyRed: false,
+				ID:          subscription.UserID,
+			})
+			if err != nil {
+				return err
+			}
+			return recordSubscriptionHistory(ctx, q, subscription, subscriptionEventExpired, subscriptionSourceSystem, "")
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		cfg.emitChirpyRedWebhook(ctx, true, subscription)
+		expired++
+	}
+	if expired > 0 {
+		fmt.Printf("expired %d subscriptions\n", expired)
+	}
+}
+
+func (cfg *apiConfig) getSubscription(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	subscription, err := cfg.db.GetSubscriptionByUserID(req.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(subscriptionFromDatabase(subscription))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func subscriptionFromDatabase(subscription database.Subscription) Subscription {
+	return Subscription{
+		Plan:               subscription.Plan,
+		Status:             subscription.Status,
+		CurrentPeriodStart: subscription.CurrentPeriodStart,
+		CurrentPeriodEnd:   subscription.CurrentPeriodEnd,
+	}
+}
+
+func subscriptionHistoryFromDatabase(history []database.SubscriptionHistory) []SubscriptionHistoryEntry {
+	entries := []SubscriptionHistoryEntry{}
+	for _, entry := range history {
+		entries = append(entries, SubscriptionHistoryEntry{
+			CreatedAt:   entry.CreatedAt,
+			Event:       entry.Event,
+			Plan:        entry.Plan,
+			Status:      entry.Status,
+			PeriodStart: entry.PeriodStart,
+			PeriodEnd:   entry.PeriodEnd,
+			Source:      entry.Source,
+		})
+	}
+	return entries
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ifeanyibatman/chirpy/internal/billing"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+	"github.com/ifeanyibatman/chirpy/internal/entitlements"
+)
+
+func TestResolveSubscriptionChange(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	current := database.Subscription{
+		Plan:               "chirpy_red_plus",
+		Status:             subscriptionActive,
+		CurrentPeriodStart: now.Add(-10 * day),
+		CurrentPeriodEnd:   now.Add(20 * day),
+	}
+	lapsed := current
+	lapsed.CurrentPeriodStart = now.Add(-40 * day)
+	lapsed.CurrentPeriodEnd = now.Add(-10 * day)
+	expired := current
+	expired.Status = subscriptionExpired
+
+	tests := []struct {
+		name       string
+		change     subscriptionChange
+		current    *database.Subscription
+		wantPlan   string
+		wantStatus string
+		wantStart  time.Time
+		wantEnd    time.Time
+	}{
+		{
+			name:       "grant without a subscription starts now",
+			change:     grantedSubscription(entitlements.PlanChirpyRed, 30, subscriptionEventPromoRedeemed, subscriptionSourcePromo, "CODE"),
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionGranted,
+			wantStart:  now,
+			wantEnd:    now.Add(30 * day),
+		},
+		{
+			name:       "grant extends a current subscription",
+			change:     grantedSubscription(entitlements.PlanChirpyRed, 30, subscriptionEventGifted, subscriptionSourceGift, "gift"),
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionActive,
+			wantStart:  current.CurrentPeriodStart,
+			wantEnd:    current.CurrentPeriodEnd.Add(30 * day),
+		},
+		{
+			name:       "grant after the period ran out starts now",
+			change:     grantedSubscription(entitlements.PlanChirpyRed, 30, subscriptionEventPromoRedeemed, subscriptionSourcePromo, "CODE"),
+			current:    &lapsed,
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionGranted,
+			wantStart:  now,
+			wantEnd:    now.Add(30 * day),
+		},
+		{
+			name:       "grant to an expired subscription starts now",
+			change:     grantedSubscription(entitlements.PlanChirpyRed, 30, subscriptionEventPromoRedeemed, subscriptionSourcePromo, "CODE"),
+			current:    &expired,
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionGranted,
+			wantStart:  now,
+			wantEnd:    now.Add(30 * day),
+		},
+		{
+			name:       "upgrade without a subscription defaults to Chirpy Red for a month",
+			change:     billingSubscriptionChanges[billing.EventStarted],
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionActive,
+			wantStart:  now,
+			wantEnd:    now.AddDate(0, 1, 0),
+		},
+		{
+			name:       "upgrade starts a fresh period on the current plan",
+			change:     billingSubscriptionChanges[billing.EventStarted],
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionActive,
+			wantStart:  now,
+			wantEnd:    now.AddDate(0, 1, 0),
+		},
+		{
+			name:       "upgrade keeps an explicit plan",
+			change:     subscriptionChange{Event: subscriptionEventUpgraded, Plan: entitlements.PlanChirpyRed, Status: subscriptionActive},
+			current:    &current,
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionActive,
+			wantStart:  now,
+			wantEnd:    now.AddDate(0, 1, 0),
+		},
+		{
+			name:       "renewal starts where the current period ends",
+			change:     billingSubscriptionChanges[billing.EventRenewed],
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionActive,
+			wantStart:  current.CurrentPeriodEnd,
+			wantEnd:    current.CurrentPeriodEnd.AddDate(0, 1, 0),
+		},
+		{
+			name:       "renewal after the period ran out starts now",
+			change:     billingSubscriptionChanges[billing.EventRenewed],
+			current:    &lapsed,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionActive,
+			wantStart:  now,
+			wantEnd:    now.AddDate(0, 1, 0),
+		},
+		{
+			name: "renewal keeps the provider's period",
+			change: subscriptionChange{
+				Event:       subscriptionEventRenewed,
+				Status:      subscriptionActive,
+				PeriodStart: now.Add(day),
+				PeriodEnd:   now.Add(366 * day),
+			},
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionActive,
+			wantStart:  now.Add(day),
+			wantEnd:    now.Add(366 * day),
+		},
+		{
+			name:       "payment failure keeps the current period",
+			change:     billingSubscriptionChanges[billing.EventPaymentFailed],
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionPastDue,
+			wantStart:  current.CurrentPeriodStart,
+			wantEnd:    current.CurrentPeriodEnd,
+		},
+		{
+			name:       "payment failure without a subscription defaults to a month",
+			change:     billingSubscriptionChanges[billing.EventPaymentFailed],
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionPastDue,
+			wantStart:  now,
+			wantEnd:    now.AddDate(0, 1, 0),
+		},
+		{
+			name:       "cancellation runs to the end of the paid period",
+			change:     billingSubscriptionChanges[billing.EventCancelled],
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionCancelled,
+			wantStart:  current.CurrentPeriodStart,
+			wantEnd:    current.CurrentPeriodEnd,
+		},
+		{
+			name:       "cancellation after the period ran out expires",
+			change:     billingSubscriptionChanges[billing.EventCancelled],
+			current:    &lapsed,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionExpired,
+			wantStart:  lapsed.CurrentPeriodStart,
+			wantEnd:    lapsed.CurrentPeriodEnd,
+		},
+		{
+			name:       "ending ends the period now",
+			change:     billingSubscriptionChanges[billing.EventEnded],
+			current:    &current,
+			wantPlan:   current.Plan,
+			wantStatus: subscriptionExpired,
+			wantStart:  current.CurrentPeriodStart,
+			wantEnd:    now,
+		},
+		{
+			name:       "ending without a subscription",
+			change:     billingSubscriptionChanges[billing.EventEnded],
+			wantPlan:   entitlements.PlanChirpyRed,
+			wantStatus: subscriptionExpired,
+			wantStart:  now,
+			wantEnd:    now,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var sub database.Subscription
+			if tc.current != nil {
+				sub = *tc.current
+			}
+			got := resolveSubscriptionChange(tc.change, sub, tc.current != nil, now)
+			if got.Plan != tc.wantPlan || got.Status != tc.wantStatus {
+				t.Errorf("got %s %s, want %s %s", got.Plan, got.Status, tc.wantPlan, tc.wantStatus)
+			}
+			if !got.PeriodStart.Equal(tc.wantStart) || !got.PeriodEnd.Equal(tc.wantEnd) {
+				t.Errorf("got period %v to %v, want %v to %v", got.PeriodStart, got.PeriodEnd, tc.wantStart, tc.wantEnd)
+			}
+		})
+	}
+}
+
+// TestSubscriptionLifecycle runs one subscription through the billing
+// events in order, each applied to the subscription the last one left.
+func TestSubscriptionLifecycle(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(2026, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	steps := []struct {
+		name       string
+		at         time.Time
+		event      billing.EventType
+		wantStatus string
+		wantStart  time.Time
+		wantEnd    time.Time
+	}{
+		{name: "upgrade", at: start, event: billing.EventStarted, wantStatus: subscriptionActive, wantStart: start, wantEnd: date(time.February, 1)},
+		{name: "renewal before the period ends", at: date(time.January, 28), event: billing.EventRenewed, wantStatus: subscriptionActive, wantStart: date(time.February, 1), wantEnd: date(time.March, 1)},
+		{name: "payment failure", at: date(time.March, 2), event: billing.EventPaymentFailed, wantStatus: subscriptionPastDue, wantStart: date(time.February, 1), wantEnd: date(time.March, 1)},
+		{name: "renewal once payment succeeds", at: date(time.March, 5), event: billing.EventRenewed, wantStatus: subscriptionActive, wantStart: date(time.March, 5), wantEnd: date(time.April, 5)},
+		{name: "cancellation", at: date(time.March, 20), event: billing.EventCancelled, wantStatus: subscriptionCancelled, wantStart: date(time.March, 5), wantEnd: date(time.April, 5)},
+		{name: "expiry", at: date(time.April, 5), event: billing.EventEnded, wantStatus: subscriptionExpired, wantStart: date(time.March, 5), wantEnd: date(time.April, 5)},
+	}
+
+	var current database.Subscription
+	hasCurrent := false
+	for _, step := range steps {
+		got := resolveSubscriptionChange(billingSubscriptionChanges[step.event], current, hasCurrent, step.at)
+		if got.Plan != entitlements.PlanChirpyRed || got.Status != step.wantStatus {
+			t.Errorf("%s: got %s %s, want %s %s", step.name, got.Plan, got.Status, entitlements.PlanChirpyRed, step.wantStatus)
+		}
+		if !got.PeriodStart.Equal(step.wantStart) || !got.PeriodEnd.Equal(step.wantEnd) {
+			t.Errorf("%s: got period %v to %v, want %v to %v", step.name, got.PeriodStart, got.PeriodEnd, step.wantStart, step.wantEnd)
+		}
+		current = database.Subscription{
+			Plan:               got.Plan,
+			Status:             got.Status,
+			CurrentPeriodStart: got.PeriodStart,
+			CurrentPeriodEnd:   got.PeriodEnd,
+		}
+		hasCurrent = true
+	}
+}
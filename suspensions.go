@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/auth"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+func isSuspended(user database.User) bool {
+	return user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now())
+}
+
+func respondAccountSuspended(w http.ResponseWriter, user database.User) {
+	type errorJson struct {
+		Error          string    `json:"error"`
+		SuspendedUntil time.Time `json:"suspended_until"`
+		Reason         string    `json:"reason,omitempty"`
+	}
+	dat, err := json.Marshal(errorJson{
+		Error:          fmt.Sprintf("Account suspended until %s", user.SuspendedUntil.Time.UTC().Format(time.RFC3339)),
+		SuspendedUntil: user.SuspendedUntil.Time,
+		Reason:         user.SuspensionReason.String,
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(dat)
+}
+
+// middlewareBlockSuspended rejects writes from suspended accounts. Requests
+// without a valid access token are passed through so the handler can answer
+// them with its usual 401.
+func (cfg *apiConfig) middlewareBlockSuspended(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token, err := auth.GetBearerToken(req.Header)
+		if err != nil {
+			next(w, req)
+			return
+		}
+		userID, err := auth.ValidateJWT(token, cfg.jwt_secret)
+		if err != nil {
+			next(w, req)
+			return
+		}
+		user, err := cfg.db.GetUserByID(req.Context(), userID)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if isSuspended(user) {
+			respondAccountSuspended(w, user)
+			return
+		}
+		next(w, req)
+	}
+}
+
+// suspendUser suspends an account until the given time and signs it out
+// everywhere. Access tokens already issued stay valid until they expire, but
+// middlewareBlockSuspended stops them from being used for writes.
+func suspendUser(ctx context.Context, q *database.Queries, userID uuid.UUID, until time.Time, reason string) (database.User, error) {
+	user, err := q.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil:   sql.NullTime{Time: until, Valid: true},
+		SuspensionReason: sql.NullString{String: reason, Valid: reason != ""},
+		ID:               userID,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, q.RevokeRefreshTokensForUser(ctx, userID)
+}
+
+type ModeratedUser struct {
+	User
+	Role             string     `json:"role"`
+	SuspendedUntil   *time.Time `json:"suspended_until"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	ShadowBanned     bool       `json:"shadow_banned"`
+}
+
+func (cfg *apiConfig) updateUserSuspension(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Reason string `json:"reason"`
+		// SuspendFor is a Go duration such as "72h".
+		SuspendFor string `json:"suspend_for"`
+	}
+
+	moderatorID, targetID, ok := cfg.moderationTarget(w, req)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(req.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if params.Reason == "" {
+		respondWithError(w, http.StatusBadRequest, "A reason is required")
+		return
+	}
+	suspendFor := defaultSuspension
+	if params.SuspendFor != "" {
+		suspendFor, err = time.ParseDuration(params.SuspendFor)
+		if err != nil || suspendFor <= 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid suspension duration")
+			return
+		}
+	}
+
+	user, err := suspendUser(req.Context(), cfg.db, targetID, time.Now().Add(suspendFor), params.Reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.respondWithModeratedUser(w, req, moderatorID, "suspend_user", params.Reason, user)
+}
+
+func (cfg *apiConfig) deleteUserSuspension(w http.ResponseWriter, req *http.Request) {
+	moderatorID, targetID, ok := cfg.moderationTarget(w, req)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.LiftUserSuspension(req.Context(), targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	cfg.respondWithModeratedUser(w, req, moderatorID, "lift_suspension", "", user)
+}
+
+func (cfg *apiConfig) updateUserShadowBan(w http.ResponseWriter, req *http.Request) {
+	cfg.setShadowBanned(w, req, true)
+}
+
+func (cfg *apiConfig) deleteUserShadowBan(w http.ResponseWriter, req *http.Request) {
+	cfg.setShadowBanned(w, req, false)
+}
+
+// setShadowBanned toggles shadow-banning. A shadow-banned user keeps using
+// Chirpy as normal, but their chirps are only returned to themselves.
+func (cfg *apiConfig) setShadowBanned(w http.ResponseWriter, req *http.Request, banned bool) {
+	moderatorID, targetID, ok := cfg.moderationTarget(w, req)
+	if !ok {
+		return
+	}
+	user, err := cfg.db.SetUserShadowBanned(req.Context(), database.SetUserShadowBannedParams{
+		ShadowBanned: banned,
+		ID:           targetID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	action := "shadow_ban"
+	if !banned {
+		action = "lift_shadow_ban"
+	}
+	cfg.respondWithModeratedUser(w, req, moderatorID, action, "", user)
+}
+
+// moderationTarget loads the acting moderator from the access token and the
+// target user from the path. Moderators can only act on users ranking below
+// them, so a moderator can't suspend another moderator or an admin.
+func (cfg *apiConfig) moderationTarget(w http.ResponseWriter, req *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	moderator, ok := cfg.currentUser(req)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return uuid.Nil, uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(req.PathValue("userID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return uuid.Nil, uuid.Nil, false
+	}
+	target, err := cfg.db.GetUserByID(req.Context(), targetID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !auth.Outranks(moderator.Role, target.Role) {
+		respondWithError(w, http.StatusForbidden, "You can only moderate users with a lower role than yours")
+		return uuid.Nil, uuid.Nil, false
+	}
+	return moderator.ID, targetID, true
+}
+
+// respondWithModeratedUser records the action in the audit log and responds
+// with the user's moderation state.
+func (cfg *apiConfig) respondWithModeratedUser(w http.ResponseWriter, req *http.Request, moderatorID uuid.UUID, action, note string, user database.User) {
+	_, err := cfg.db.CreateModerationAction(req.Context(), database.CreateModerationActionParams{
+		ModeratorID:  moderatorID,
+		Action:       action,
+		TargetUserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Note:         note,
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(moderatedUserFromDatabase(user))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func moderatedUserFromDatabase(user database.User) ModeratedUser {
+	res := ModeratedUser{
+		User: User{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+		},
+		Role:         user.Role,
+		ShadowBanned: user.ShadowBanned,
+	}
+	if isSuspended(user) {
+		res.SuspendedUntil = &user.SuspendedUntil.Time
+		res.SuspensionReason = user.SuspensionReason.String
+	}
+	return res
+}
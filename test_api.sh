@@ -6,9 +6,22 @@ URL="http://localhost:8080"
 echo "🧪 Starting Chirpy API Tests..."
 echo "--------------------------------"
 
+# /admin/reset needs an admin. admin_login signs up the admin account (it may
+# already exist from an earlier run), grants it the admin role with the CLI
+# and logs in. The reset deletes the account, so it runs before every reset.
+ADMIN_EMAIL="admin@example.com"
+ADMIN_PASSWORD="correct-horse-battery-staple"
+admin_login() {
+    curl -s -o /dev/null -X POST $URL/api/users -d "{\"email\": \"$ADMIN_EMAIL\", \"password\": \"$ADMIN_PASSWORD\"}"
+    go run . grant-admin $ADMIN_EMAIL > /dev/null
+    ADMIN_RESP=$(curl -s -X POST $URL/api/login -d "{\"email\": \"$ADMIN_EMAIL\", \"password\": \"$ADMIN_PASSWORD\"}")
+    ADMIN_TOKEN=$(echo $ADMIN_RESP | grep -o '"token":"[^"]*' | cut -d'"' -f4)
+}
+
 # 1. Reset Database
 echo "1. Resetting Database..."
-curl -X POST $URL/admin/reset
+admin_login
+curl -X POST $URL/admin/reset -H "Authorization: Bearer $ADMIN_TOKEN"
 echo -e "\n"
 
 # 2. Create User
@@ -54,7 +67,8 @@ echo -e "\n"
 
 # 8. Result Database
 echo "8. Create Final Reset..."
-curl -X POST $URL/admin/reset
+admin_login
+curl -X POST $URL/admin/reset -H "Authorization: Bearer $ADMIN_TOKEN"
 echo -e "\n"
 
 echo "--------------------------------"
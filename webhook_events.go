@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ifeanyibatman/chirpy/internal/database"
+)
+
+const (
+	webhookEventReceived   = "received"
+	webhookEventProcessing = "processing"
+	webhookEventProcessed  = "processed"
+	webhookEventIgnored    = "ignored"
+	webhookEventFailed     = "failed"
+)
+
+var webhookEventStatuses = map[string]bool{
+	webhookEventReceived:   true,
+	webhookEventProcessing: true,
+	webhookEventProcessed:  true,
+	webhookEventIgnored:    true,
+	webhookEventFailed:     true,
+}
+
+// errWebhookEventClaimed means the event is already being applied by another
+// request, or has been applied since it was read.
+var errWebhookEventClaimed = errors.New("webhook event is already being processed")
+
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Status      string          `json:"status"`
+	Error       *string         `json:"error"`
+	Attempts    int32           `json:"attempts"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+// recordWebhookEvent stores an inbound event. If the sender already
+// delivered an event with this ID, the stored one is returned instead.
+func (cfg *apiConfig) recordWebhookEvent(ctx context.Context, source, eventID, eventType string, payload []byte) (database.WebhookEvent, error) {
+	event, err := cfg.db.CreateWebhookEvent(ctx, database.CreateWebhookEventParams{
+		Source:    source,
+		EventID:   eventID,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return cfg.db.GetWebhookEventBySourceID(ctx, database.GetWebhookEventBySourceIDParams{
+			Source:  source,
+			EventID: eventID,
+		})
+	}
+	return event, err
+}
+
+// processWebhookEvent claims a stored event, applies it and records the
+// outcome on it. A failure to apply the event is recorded, not returned. If
+// the event can't be claimed, it returns errWebhookEventClaimed.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) (database.WebhookEvent, error) {
+	event, err := cfg.db.ClaimWebhookEvent(ctx, event.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.WebhookEvent{}, errWebhookEventClaimed
+	}
+	if err != nil {
+		return database.WebhookEvent{}, err
+	}
+
+	params := database.FinishWebhookEventParams{ID: event.ID}
+	applied, err := cfg.applyBillingWebhook(ctx, event)
+	switch {
+	case err != nil:
+		params.Status = webhookEventFailed
+		params.Error = sql.NullString{String: err.Error(), Valid: true}
+	case applied:
+		params.Status = webhookEventProcessed
+	default:
+		params.Status = webhookEventIgnored
+	}
+	return cfg.db.FinishWebhookEvent(ctx, params)
+}
+
+func (cfg *apiConfig) getWebhookEvents(w http.ResponseWriter, req *http.Request) {
+	status := req.URL.Query().Get("status")
+	if status != "" && !webhookEventStatuses[status] {
+		respondWithError(w, http.StatusBadRequest, "Unknown webhook event status")
+		return
+	}
+	limit := 100
+	if s := req.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := cfg.db.ListWebhookEvents(req.Context(), database.ListWebhookEventsParams{
+		Status:    sql.NullString{String: status, Valid: status != ""},
+		MaxEvents: int32(limit),
+	})
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	events := []WebhookEvent{}
+	for _, row := range rows {
+		events = append(events, webhookEventFromDatabase(row))
+	}
+
+	dat, err := json.Marshal(events)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func (cfg *apiConfig) replayWebhookEvent(w http.ResponseWriter, req *http.Request) {
+	eventID, err := uuid.Parse(req.PathValue("eventID"))
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	event, err := cfg.db.GetWebhookEvent(req.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// Only failed events are replayed; anything else has either been
+	// applied already or is still being handled.
+	if event.Status != webhookEventFailed {
+		respondWithError(w, http.StatusConflict, "Only failed webhook events can be replayed")
+		return
+	}
+
+	event, err = cfg.processWebhookEvent(req.Context(), event)
+	if errors.Is(err, errWebhookEventClaimed) {
+		respondWithError(w, http.StatusConflict, "Only failed webhook events can be replayed")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	dat, err := json.Marshal(webhookEventFromDatabase(event))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(dat)
+}
+
+func webhookEventFromDatabase(event database.WebhookEvent) WebhookEvent {
+	res := WebhookEvent{
+		ID:         event.ID,
+		Source:     event.Source,
+		EventID:    event.EventID,
+		EventType:  event.EventType,
+		ReceivedAt: event.ReceivedAt,
+		Status:     event.Status,
+		Attempts:   event.Attempts,
+	}
+	// Payloads are stored as received; one that isn't valid JSON is shown
+	// as a string rather than breaking the response.
+	if json.Valid(event.Payload) {
+		res.Payload = event.Payload
+	} else {
+		res.Payload, _ = json.Marshal(string(event.Payload))
+	}
+	if event.Error.Valid {
+		res.Error = &event.Error.String
+	}
+	if event.ProcessedAt.Valid {
+		res.ProcessedAt = &event.ProcessedAt.Time
+	}
+	return res
+}